@@ -0,0 +1,205 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+	"github.com/rs/zerolog/log"
+)
+
+// FanoutSample is the fanout picked at a point in time, along with the
+// observations that led to it
+type FanoutSample struct {
+	Time      time.Time
+	Fanout    int
+	DupeRatio float64
+	Loss      float64
+}
+
+// FanoutStats shows the state of the adaptive fanout
+type FanoutStats struct {
+	Active  bool
+	Current int
+	Min     int
+	Max     int
+	History []FanoutSample
+}
+
+// AdaptiveRelay sends messages to a number of random peers that is adjusted
+// while running. The fanout is lowered if most received messages are
+// duplicates, and raised if messages are lost or duplicates are rare.
+type AdaptiveRelay struct {
+	fanout   int32
+	min, max int
+
+	mtx     sync.RWMutex
+	history []FanoutSample
+}
+
+// parseAdaptiveRelay reads "start,min,max", defaulting to 8,2,32
+func parseAdaptiveRelay(params string) (RelayPolicy, error) {
+	vals := []int{8, 2, 32}
+	if params = strings.TrimSpace(params); params != "" {
+		split := strings.Split(params, ",")
+		if len(split) != 3 {
+			return nil, fmt.Errorf("adaptive relay expects \"start,min,max\"")
+		}
+		for i, s := range split {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+	}
+	if vals[1] < 1 || vals[1] > vals[2] || vals[0] < vals[1] || vals[0] > vals[2] {
+		return nil, fmt.Errorf("adaptive relay needs 1 <= min <= start <= max")
+	}
+
+	ar := new(AdaptiveRelay)
+	ar.fanout = int32(vals[0])
+	ar.min = vals[1]
+	ar.max = vals[2]
+	return ar, nil
+}
+
+func (ar *AdaptiveRelay) Targets(n network.Network, typ byte) []string {
+	targets := make([]string, atomic.LoadInt32(&ar.fanout))
+	for i := range targets {
+		targets[i] = n.RandomFlag()
+	}
+	return targets
+}
+
+func (ar *AdaptiveRelay) Reply(typ byte) bool { return true }
+
+// Adjust picks the new fanout based on the ratio of duplicates among received
+// messages and the share of lost messages
+func (ar *AdaptiveRelay) Adjust(dupeRatio, loss float64) int {
+	fanout := int(atomic.LoadInt32(&ar.fanout))
+	switch {
+	case loss > adaptiveLoss:
+		fanout *= 2
+	case dupeRatio > adaptiveDupeHigh:
+		fanout--
+	case dupeRatio < adaptiveDupeLow:
+		fanout++
+	}
+	if fanout < ar.min {
+		fanout = ar.min
+	}
+	if fanout > ar.max {
+		fanout = ar.max
+	}
+	atomic.StoreInt32(&ar.fanout, int32(fanout))
+
+	ar.mtx.Lock()
+	ar.history = append(ar.history, FanoutSample{Time: time.Now(), Fanout: fanout, DupeRatio: dupeRatio, Loss: loss})
+	if len(ar.history) > adaptiveHistory {
+		ar.history = ar.history[len(ar.history)-adaptiveHistory:]
+	}
+	ar.mtx.Unlock()
+	return fanout
+}
+
+func (ar *AdaptiveRelay) Stats() FanoutStats {
+	ar.mtx.RLock()
+	defer ar.mtx.RUnlock()
+	return FanoutStats{
+		Active:  true,
+		Current: int(atomic.LoadInt32(&ar.fanout)),
+		Min:     ar.min,
+		Max:     ar.max,
+		History: append([]FanoutSample(nil), ar.history...),
+	}
+}
+
+// Recent returns the last samples, newest first
+func (fs FanoutStats) Recent() []FanoutSample {
+	recent := make([]FanoutSample, 0, 10)
+	for i := len(fs.History) - 1; i >= 0 && len(recent) < cap(recent); i-- {
+		recent = append(recent, fs.History[i])
+	}
+	return recent
+}
+
+// lossCounters are the running totals of the delivery outcomes that count
+// towards the loss of the adaptive fanout
+type lossCounters struct {
+	acks                       uint64 // ACKs received
+	gaps                       uint64 // ACK gaps that needed a MissingReply or never closed
+	entries, entriesLost       uint64 // entries completed or timed out
+	minutes, minutesIncomplete uint64 // minutes with all or some EOMs missing
+}
+
+func (a *App) lossCounters() lossCounters {
+	rs := a.repair.Stats()
+	es := a.entries.Stats()
+	auth := a.auth.Stats()
+	return lossCounters{
+		acks:              atomic.LoadUint64(&a.stats.NonDupeMessages[ACK]),
+		gaps:              rs.Repaired + rs.Failed,
+		entries:           es.Completed + es.Incomplete,
+		entriesLost:       es.Incomplete,
+		minutes:           auth.Complete + auth.Incomplete,
+		minutesIncomplete: auth.Incomplete,
+	}
+}
+
+// deliveryLoss is the worst share of lost deliveries between two samples:
+// ACKs that gossip didn't deliver in order, entries that didn't arrive in
+// full, and minutes with missing EOMs. The EOM signal needs authority roles,
+// the other two only need the load generator.
+func deliveryLoss(prev, cur lossCounters) float64 {
+	ratio := func(lost, total uint64) float64 {
+		if total == 0 {
+			return 0
+		}
+		if lost > total {
+			return 1
+		}
+		return float64(lost) / float64(total)
+	}
+	loss := ratio(cur.gaps-prev.gaps, cur.acks-prev.acks)
+	if l := ratio(cur.entriesLost-prev.entriesLost, cur.entries-prev.entries); l > loss {
+		loss = l
+	}
+	if l := ratio(cur.minutesIncomplete-prev.minutesIncomplete, cur.minutes-prev.minutes); l > loss {
+		loss = l
+	}
+	return loss
+}
+
+// adaptFanout periodically feeds the observed duplicates and losses to the
+// adaptive relay policy, if it is in use
+func (a *App) adaptFanout() {
+	var lastTotal, lastNew uint64
+	var lastLoss lossCounters
+	ticker := time.NewTicker(adaptiveInterval)
+	for range ticker.C {
+		var total, fresh uint64
+		for typ := ACK; typ < StartRecording; typ++ {
+			total += atomic.LoadUint64(&a.stats.Messages[typ])
+			fresh += atomic.LoadUint64(&a.stats.NonDupeMessages[typ])
+		}
+		counters := a.lossCounters()
+
+		dTotal, dNew := total-lastTotal, fresh-lastNew
+		loss := deliveryLoss(lastLoss, counters)
+		lastTotal, lastNew, lastLoss = total, fresh, counters
+
+		ar, ok := a.relay.current().(*AdaptiveRelay)
+		if !ok || dTotal == 0 {
+			continue
+		}
+
+		dupeRatio := float64(dTotal-dNew) / float64(dTotal)
+		fanout := ar.Adjust(dupeRatio, loss)
+		log.Debug().Int("fanout", fanout).Float64("dupes", dupeRatio).Float64("loss", loss).Msg("adaptive fanout")
+	}
+}
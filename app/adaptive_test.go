@@ -0,0 +1,92 @@
+package app
+
+import "testing"
+
+func Test_parseAdaptiveRelay(t *testing.T) {
+	tests := []struct {
+		params        string
+		start, lo, hi int
+		err           bool
+	}{
+		{"", 8, 2, 32, false},
+		{"4,1,16", 4, 1, 16, false},
+		{" 3 , 3 , 3 ", 3, 3, 3, false},
+		{"4,1", 0, 0, 0, true},
+		{"4,1,16,2", 0, 0, 0, true},
+		{"a,1,16", 0, 0, 0, true},
+		{"4,0,16", 0, 0, 0, true},
+		{"4,8,2", 0, 0, 0, true},
+		{"1,2,16", 0, 0, 0, true},
+		{"17,2,16", 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		policy, err := parseAdaptiveRelay(tt.params)
+		if (err != nil) != tt.err {
+			t.Errorf("parseAdaptiveRelay(%q) error = %v, want error %v", tt.params, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		stats := policy.(*AdaptiveRelay).Stats()
+		if stats.Current != tt.start || stats.Min != tt.lo || stats.Max != tt.hi {
+			t.Errorf("parseAdaptiveRelay(%q) = %d,%d,%d, want %d,%d,%d", tt.params, stats.Current, stats.Min, stats.Max, tt.start, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestAdaptiveRelay_Adjust(t *testing.T) {
+	tests := []struct {
+		name            string
+		start           int
+		dupeRatio, loss float64
+		want            int
+	}{
+		{"loss doubles", 4, 0.9, 0.5, 8},
+		{"loss capped at max", 12, 0.1, 0.5, 16},
+		{"many dupes lower", 4, 0.9, 0, 3},
+		{"dupes floored at min", 2, 0.9, 0, 2},
+		{"few dupes raise", 4, 0.1, 0, 5},
+		{"few dupes capped at max", 16, 0.1, 0, 16},
+		{"in between keeps", 4, 0.6, 0, 4},
+		{"loss at threshold ignored", 4, 0.6, adaptiveLoss, 4},
+	}
+	for _, tt := range tests {
+		ar := &AdaptiveRelay{fanout: int32(tt.start), min: 2, max: 16}
+		if got := ar.Adjust(tt.dupeRatio, tt.loss); got != tt.want {
+			t.Errorf("%s: Adjust(%v, %v) = %d, want %d", tt.name, tt.dupeRatio, tt.loss, got, tt.want)
+		}
+		stats := ar.Stats()
+		if stats.Current != tt.want || len(stats.History) != 1 || stats.History[0].Loss != tt.loss {
+			t.Errorf("%s: stats = %+v", tt.name, stats)
+		}
+	}
+
+	ar := &AdaptiveRelay{fanout: 4, min: 2, max: 16}
+	for i := 0; i < adaptiveHistory+5; i++ {
+		ar.Adjust(0.6, 0)
+	}
+	if n := len(ar.Stats().History); n != adaptiveHistory {
+		t.Errorf("history has %d samples, want %d", n, adaptiveHistory)
+	}
+}
+
+func Test_deliveryLoss(t *testing.T) {
+	prev := lossCounters{acks: 100, gaps: 1, entries: 10, entriesLost: 1, minutes: 5, minutesIncomplete: 1}
+	tests := []struct {
+		name string
+		cur  lossCounters
+		want float64
+	}{
+		{"nothing happened", prev, 0},
+		{"ack gaps without roles", lossCounters{acks: 200, gaps: 11, entries: 10, entriesLost: 1, minutes: 5, minutesIncomplete: 1}, 0.1},
+		{"entries lost", lossCounters{acks: 200, gaps: 1, entries: 20, entriesLost: 3, minutes: 5, minutesIncomplete: 1}, 0.2},
+		{"missing eoms", lossCounters{acks: 200, gaps: 2, entries: 20, entriesLost: 1, minutes: 9, minutesIncomplete: 3}, 0.5},
+		{"gaps of older acks", lossCounters{acks: 101, gaps: 4, entries: 10, entriesLost: 1, minutes: 5, minutesIncomplete: 1}, 1},
+	}
+	for _, tt := range tests {
+		if got := deliveryLoss(prev, tt.cur); got != tt.want {
+			t.Errorf("%s: deliveryLoss() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package app
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"math/rand"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/WhoSoup/factom-p2p-tps/network"
@@ -14,17 +14,42 @@ import (
 )
 
 type App struct {
-	n      network.Network
-	gen    *Generator
-	replay *Replay
-
-	Height     int
-	Minute     int
-	mtx        sync.RWMutex
-	recordOnce sync.Once
+	id        uint64
+	n         network.Network
+	gen       *Generator
+	replay    *AuditedDedup
+	cluster   *Cluster
+	auth      *Authority
+	clock     *Clock
+	cost      *CostModel
+	relay     *RelayConfig
+	sweep     *Sweep
+	repair    *Repair
+	dbstate   *DBStates
+	entries   *Entries
+	digests   *Digests
+	hops      *Hops
+	tracer    *Traces
+	neighbors *Neighbors
+	firstPeer int64
+	launched  int32 // atomic, set once n is usable
+
+	workerMtx      sync.Mutex
+	workerCount    int
+	workersStarted bool
+	pool           []*worker
+	nextWorker     int
+
+	Height      int
+	Minute      int
+	minuteStart time.Time
+	mtx         sync.RWMutex
+	recordOnce  sync.Once
 
 	generate          bool
+	origin            bool
 	feds, audits, eps int
+	arrival           string
 
 	loadchange chan int
 	loadcancel func()
@@ -43,31 +68,75 @@ type Stats struct {
 	EPS      uint64
 	EPSCount uint64
 
-	Metrics network.Metrics
+	GenTarget uint64
+	GenEPS    uint64
+	GenCount  uint64
+
+	// load messages created by this node
+	Originated uint64
+
+	Metrics   network.Metrics
+	Authority AuthorityStats
+	Clock     ClockStats
+	Replay    ReplayStats
+	Repair    RepairStats
+	DBState   DBStateStats
+	Entries   EntryStats
+	Hops      []HopDist
+	Paths     []PathSample
+	Workers   []WorkerStats
+	Backlog   int
+	Fanout    FanoutStats
+	// time from launch until the first peer connected, zero until then
+	FirstPeer time.Duration
 }
 
+// the counters are updated atomically so workers don't contend for the mutex
+
 func (s *Stats) AddMsg(msg byte, dupe bool) {
-	s.mtx.Lock()
-	s.Messages[msg]++
+	atomic.AddUint64(&s.Messages[msg], 1)
 	if !dupe {
-		s.NonDupeMessages[msg]++
+		atomic.AddUint64(&s.NonDupeMessages[msg], 1)
 	}
-	s.mtx.Unlock()
 }
 
 func (s *Stats) AddSent(msg byte, count uint64) {
-	s.mtx.Lock()
-	s.Sent[msg] += count
-	s.mtx.Unlock()
+	atomic.AddUint64(&s.Sent[msg], count)
 }
 
 func (s *Stats) AddPS(eps, tps uint64) {
+	atomic.AddUint64(&s.TPSCount, tps)
+	atomic.AddUint64(&s.EPSCount, eps)
+}
+
+func (s *Stats) AddGenerated(count uint64) {
+	atomic.AddUint64(&s.GenCount, count)
+}
+
+// loadCounts returns how many of the message types created by the load
+// generator were received in total and without duplicates
+func (s *Stats) loadCounts() (received, unique uint64) {
+	for _, typ := range []byte{ACK, CommitChain, CommitEntry, RevealEntry, Transaction} {
+		received += atomic.LoadUint64(&s.Messages[typ])
+		unique += atomic.LoadUint64(&s.NonDupeMessages[typ])
+	}
+	return
+}
+
+func (s *Stats) SetGenTarget(eps uint64) {
 	s.mtx.Lock()
-	s.TPSCount += tps
-	s.EPSCount += eps
+	s.GenTarget = eps
 	s.mtx.Unlock()
 }
 
+// GenRatio is the achieved rate of the load generator compared to the requested rate
+func (s *Stats) GenRatio() float64 {
+	if s.GenTarget == 0 {
+		return 0
+	}
+	return float64(s.GenEPS) / float64(s.GenTarget)
+}
+
 func (s *Stats) Waste(b int) float64 {
 	if s.Messages[b] == 0 {
 		return 0
@@ -88,20 +157,91 @@ func NewApp() *App {
 	a.loadchange = make(chan int)
 
 	a.gen = NewGenerator(entryPercent)
+	a.workerCount = workers
 
 	rand.Seed(time.Now().UnixNano())
-	a.replay = NewReplay(time.Minute, 10)
+	a.replay = NewAuditedDedup("buckets", NewReplay(replayInterval, replaySize))
+	a.cluster = NewCluster()
+	a.auth = NewAuthority()
+	a.clock = NewClock()
+	a.cost = new(CostModel)
+	a.relay = NewRelayConfig()
+	a.sweep = new(Sweep)
+	a.id = rand.Uint64()
+	a.repair = NewRepair(a.id)
+	a.dbstate = NewDBStates()
+	a.entries = NewEntries(a.id)
+	a.digests = NewDigests()
+	a.hops = NewHops()
+	a.tracer = new(Traces)
+	a.neighbors = NewNeighbors()
 	return a
 }
 
+// SetDedup picks the duplicate detection strategy and stops the one it
+// replaces. Has to be called before Launch.
+func (a *App) SetDedup(name string) error {
+	d, err := NewDeduplicator(name)
+	if err != nil {
+		return err
+	}
+	old := a.replay
+	a.replay = NewAuditedDedup(name, d)
+	if old != nil {
+		old.Stop()
+	}
+	return nil
+}
+
+// SetPooledGenerator enables the low allocation message generator
+func (a *App) SetPooledGenerator(pooled bool) {
+	a.gen.SetPooled(pooled)
+}
+
+// Stats returns a snapshot of the stats. The message counters are copied
+// atomically since the workers keep updating them.
 func (a *App) Stats() *Stats {
-	a.stats.mtx.Lock()
-	defer a.stats.mtx.Unlock()
 	if a.n == nil {
 		return &Stats{}
 	}
-	a.stats.Metrics = a.n.Metrics()
-	return a.stats
+	a.stats.mtx.RLock()
+	s := &Stats{
+		NonDupeMessages: loadCounters(a.stats.NonDupeMessages),
+		Messages:        loadCounters(a.stats.Messages),
+		Sent:            loadCounters(a.stats.Sent),
+		TPS:             a.stats.TPS,
+		TPSCount:        atomic.LoadUint64(&a.stats.TPSCount),
+		EPS:             a.stats.EPS,
+		EPSCount:        atomic.LoadUint64(&a.stats.EPSCount),
+		GenTarget:       a.stats.GenTarget,
+		GenEPS:          a.stats.GenEPS,
+		GenCount:        atomic.LoadUint64(&a.stats.GenCount),
+		Originated:      atomic.LoadUint64(&a.stats.Originated),
+		Workers:         a.stats.Workers,
+	}
+	a.stats.mtx.RUnlock()
+
+	s.Metrics = a.n.Metrics()
+	s.Authority = a.auth.Stats()
+	s.Clock = a.clock.Stats()
+	s.Replay = a.replay.Stats()
+	s.Repair = a.repair.Stats()
+	s.DBState = a.dbstate.Stats()
+	s.Entries = a.entries.Stats()
+	s.Hops = a.hops.Dists()
+	s.Paths = a.hops.Paths(a.cluster.Names(), a.n.Name())
+	s.Backlog = a.n.Backlog()
+	s.Fanout = a.relay.FanoutStats()
+	s.FirstPeer = a.FirstPeer()
+	return s
+}
+
+func loadCounters(counters []uint64) []uint64 {
+	c := make([]uint64, len(counters))
+	for i := range counters {
+		c[i] = atomic.LoadUint64(&counters[i])
+	}
+	return c
 }
 
 func (a *App) generateLoad() {
@@ -113,6 +253,15 @@ func (a *App) generateLoad() {
 
 		if l <= 0 {
 			log.Info().Msg("stopping load gen")
+			a.stats.SetGenTarget(0)
+			continue
+		}
+
+		a.mtx.RLock()
+		arrival, err := NewArrival(a.arrival)
+		a.mtx.RUnlock()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to start load gen")
 			continue
 		}
 
@@ -123,16 +272,13 @@ func (a *App) generateLoad() {
 			close(stopper)
 		}
 
-		go func(eps int) {
-			ep10ms := float64(eps) / 100
-
-			burst := int(ep10ms)
-			leftover := ep10ms - float64(burst)
-
-			log.Info().Int("eps", eps).Int("burst", burst).Float64("leftover", leftover).Msg("starting load gen")
+		go func(eps int, arrival Arrival) {
+			log.Info().Int("eps", eps).Str("arrival", fmt.Sprintf("%T", arrival)).Msg("starting load gen")
 			defer log.Info().Int("eps", eps).Msg("ending load gen")
-			ticker := time.NewTicker(time.Millisecond * 10)
-			accumulator := 0.0
+			a.stats.SetGenTarget(uint64(eps))
+
+			ticker := time.NewTicker(generatorTick)
+			defer ticker.Stop()
 			for range ticker.C {
 				select {
 				case <-stopper:
@@ -140,33 +286,60 @@ func (a *App) generateLoad() {
 				default:
 				}
 
-				for i := 0; i < burst; i++ {
-					a.SendRandomizedMessage()
-				}
+				a.mtx.RLock()
+				since := time.Since(a.minuteStart)
+				a.mtx.RUnlock()
 
-				accumulator += leftover
-				if accumulator > 1 {
-					accumulator--
+				count := arrival.Count(float64(eps), generatorTick, since)
+				for i := 0; i < count; i++ {
 					a.SendRandomizedMessage()
 				}
+				a.stats.AddGenerated(uint64(count))
 			}
-		}(l)
+		}(l, arrival)
 	}
 }
 
 func (a *App) SendRandomizedMessage() {
 	mtype := a.gen.WeightedRandomType()
-	a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(mtype))
-	a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(ACK))
+	want := entryParts
+	if mtype == Transaction {
+		want = transactionParts
+	}
+	tag := a.entries.Tag(want, a.clock.Now())
+
+	a.n.DeliverMessage(a.n.RandomFlag(), a.createPart(mtype, tag, partCommit))
+	a.n.DeliverMessage(a.n.RandomFlag(), a.createPart(ACK, tag, partCommitAck))
 
 	if mtype != Transaction {
-		a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(RevealEntry))
-		a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(ACK))
+		a.n.DeliverMessage(a.n.RandomFlag(), a.createPart(RevealEntry, tag, partReveal))
+		a.n.DeliverMessage(a.n.RandomFlag(), a.createPart(ACK, tag, partRevealAck))
+		atomic.AddUint64(&a.stats.Originated, 4)
+	} else {
+		atomic.AddUint64(&a.stats.Originated, 2)
 	}
 
 	runtime.Gosched()
 }
 
+// createPart creates a message that is part of the tagged entry
+func (a *App) createPart(typ byte, tag EntryTag, part byte) []byte {
+	msg := a.stamp(a.gen.CreateMessage(typ))
+	tag.encode(msg, part)
+	if typ == ACK {
+		a.repair.ChainAck(msg)
+	}
+	height, minute := a.clock.MasterAt(tag.Created)
+	a.digests.Add(height, minute, messageHash(msg))
+	return msg
+}
+
+// stamp prepares the hop trailer of a message created by this node
+func (a *App) stamp(msg []byte) []byte {
+	stampTrailer(msg, a.id)
+	return msg
+}
+
 func (a *App) StartRecording() {
 	a.recordOnce.Do(func() { go a.record() })
 }
@@ -180,6 +353,10 @@ func (a *App) record() {
 
 	fmt.Fprintf(f, "Recording session %s\n", time.Now())
 	fmt.Fprintln(f, "====================")
+	relay, params := a.relay.Policy()
+	fmt.Fprintf(f, "Relay=%s %s\n", relay, params)
+	fmt.Fprint(f, a.n.Config())
+	fmt.Fprintln(f, "====================")
 
 	t := time.NewTicker(time.Second)
 	for range t.C {
@@ -187,107 +364,506 @@ func (a *App) record() {
 			fmt.Fprintln(f, time.Now(), "No stats yet")
 			continue
 		}
+		m := a.n.Metrics()
 		a.stats.mtx.RLock()
-		fmt.Fprintf(f, "%d, %d, %d, %d, %d, %d, %d, %d, %d\n", time.Now().Unix(), a.stats.EPS, a.stats.EPSCount, a.stats.TPS, a.stats.TPSCount, a.stats.Metrics.BytesDown, a.stats.Metrics.BytesUp, a.stats.Metrics.MessagesDown, a.stats.Metrics.MessagesUp)
+		fmt.Fprintf(f, "%d, %d, %d, %d, %d, %d, %d, %d, %d, %d, %d, %d\n", time.Now().Unix(), a.stats.EPS, atomic.LoadUint64(&a.stats.EPSCount), a.stats.TPS, atomic.LoadUint64(&a.stats.TPSCount), m.BytesDown, m.BytesUp, m.MessagesDown, m.MessagesUp, a.stats.GenTarget, a.stats.GenEPS, a.relay.FanoutStats().Current)
 		a.stats.mtx.RUnlock()
 	}
 }
 
-func (a *App) worker() {
-	for {
-		peer, msg := a.n.ReadMessage()
-		if len(msg) == 0 {
-			log.Warn().Str("peer", peer).Msg("received invalid message")
-			continue
+// process handles a single message received from the network
+func (a *App) process(peer string, msg []byte) {
+	if len(msg) == 0 {
+		log.Warn().Str("peer", peer).Msg("received invalid message")
+		return
+	}
+
+	hash := messageHash(msg)
+	if a.replay.Dupe(hash) {
+		a.stats.AddMsg(msg[0], true)
+		return
+	}
+
+	a.cost.Apply(costType(msg))
+
+	sent := byte(0)
+	switch msg[0] {
+	case StartRecording:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.StartRecording()
+		sent = msg[0]
+	case ACK, EOM, Heartbeat, CommitChain, CommitEntry, RevealEntry, DBSig, Transaction: // rebroadcast
+		if a.relayMessage(msg) {
+			sent = msg[0]
+		}
+	case MissingMsg: // reply if we have it and the relay policy allows it
+		if a.relay.Reply(msg[0]) && a.answerMissing(peer, msg) {
+			sent = MissingReply
+		}
+	case MissingReply:
+		a.handleMissingReply(peer, msg)
+	case Tracer:
+		if a.handleTracer(peer, msg) {
+			sent = msg[0]
 		}
+	case PeerHello:
+		a.handleHello(peer, msg)
+	case DBStateRequest: // reply directly to the requesting peer
+		if id, ok := decodeDBState(msg); ok && a.relay.Reply(msg[0]) {
+			reply := a.gen.CreateMessage(DBStateReply)
+			encodeDBState(reply, id)
+			a.n.DeliverMessage(peer, reply)
+			sent = DBStateReply
+		}
+	case NodeAnnounce:
+		a.n.DeliverMessage(a.n.BroadcastFlag(), msg)
+		a.handleAnnounce(peer, msg)
+		sent = msg[0]
+	case LoadControl:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.handleLoadControl(peer, msg)
+		sent = msg[0]
+	case RoleControl:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.handleRoleControl(peer, msg)
+		sent = msg[0]
+	case ClockControl:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.handleClockControl(peer, msg)
+		sent = msg[0]
+	case CostControl:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.handleCostControl(peer, msg)
+		sent = msg[0]
+	case RelayControl:
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
+		a.handleRelayControl(peer, msg)
+		sent = msg[0]
+	case DBStateReply:
+		if id, ok := decodeDBState(msg); ok {
+			a.dbstate.Reply(id)
+		}
+	default:
+		log.Warn().Str("peer", peer).Int("len", len(msg)).Msg("received invalid message with payload")
+	}
+	a.stats.AddMsg(msg[0], false)
+	if sent != 0 {
+		a.stats.AddSent(sent, 1)
+	}
 
-		hash := sha256.Sum256(msg)
-		if a.replay.Dupe(fmt.Sprintf("%x", hash)) {
-			a.stats.AddMsg(msg[0], true)
-		} else {
-			sent := byte(0)
-			switch msg[0] {
-			case StartRecording:
-				a.n.DeliverMessage(a.n.FullBroadcastFlag(), msg)
-				a.StartRecording()
-				sent = msg[0]
-			case ACK, EOM, Heartbeat, CommitChain, CommitEntry, RevealEntry, DBSig, Transaction: // rebroadcast
-				a.n.DeliverMessage(a.n.BroadcastFlag(), msg)
-				sent = msg[0]
-			case MissingMsg: // rebroadcast and reply
-				a.n.DeliverMessage(a.n.BroadcastFlag(), msg)
-				a.n.DeliverMessage(peer, a.gen.CreateMessage(MissingReply))
-				sent = MissingReply
-			case DBStateRequest:
-				a.n.DeliverMessage(a.n.BroadcastFlag(), msg)
-				a.n.DeliverMessage(peer, a.gen.CreateMessage(DBStateReply))
-				sent = DBStateReply
-			case MissingReply, DBStateReply:
-				// ignore
-			default:
-				log.Warn().Str("peer", peer).Int("len", len(msg)).Msg("received invalid message with payload")
-			}
-			a.stats.AddMsg(msg[0], false)
-			if sent != 0 {
-				a.stats.AddSent(sent, 1)
-			}
+	switch msg[0] {
+	case CommitChain, CommitEntry:
+		a.stats.AddPS(0, 1)
+	case RevealEntry, Transaction:
+		a.stats.AddPS(1, 1)
+	case EOM, DBSig:
+		a.auth.Receive(msg)
+	case ACK:
+		a.repair.Ack(msg, hash, false)
+	}
 
-			switch msg[0] {
-			case CommitChain, CommitEntry:
-				a.stats.AddPS(0, 1)
-			case RevealEntry, Transaction:
-				a.stats.AddPS(1, 1)
-			}
+	if hasTrailer(msg[0]) {
+		a.hops.Receive(msg)
+	}
 
-			if a.generate && msg[0] == ACK && rand.Float64() < missingmsgLikelihood {
-				a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(MissingMsg))
-			}
+	switch msg[0] {
+	case ACK, CommitChain, CommitEntry, RevealEntry, Transaction:
+		a.entries.Receive(msg, a.clock.Now())
+		a.digestMessage(msg, hash)
+	}
+}
+
+// digestMessage adds a load message created by another node to the digest of
+// the minute it was created in. Messages created by this node are added when
+// they are created.
+func (a *App) digestMessage(msg []byte, hash [32]byte) {
+	et, _, ok := decodeEntryTag(msg)
+	if !ok || et.Origin == a.id {
+		return
+	}
+	height, minute := a.clock.MasterAt(et.Created)
+	a.digests.Add(height, minute, hash)
+}
+
+// Consistency compares the message digests of all nodes for each minute
+func (a *App) Consistency() []MinuteConsistency {
+	return compareDigests(a.cluster.Nodes())
+}
+
+// answerMissing sends the requested message back to the peer that asked for it
+func (a *App) answerMissing(peer string, msg []byte) bool {
+	hash, ok := decodeMissing(msg)
+	if !ok {
+		log.Warn().Str("peer", peer).Msg("received invalid missing message request")
+		return false
+	}
+	found, ok := a.repair.Answer(hash)
+	if !ok {
+		return false
+	}
+	a.n.DeliverMessage(peer, append([]byte{MissingReply}, found...))
+	return true
+}
+
+// handleMissingReply fills a gap with the message sent by a peer. The message
+// isn't relayed, the rest of the network has to repair its own gaps.
+func (a *App) handleMissingReply(peer string, msg []byte) {
+	inner := msg[1:]
+	if len(inner) == 0 || inner[0] != ACK {
+		log.Warn().Str("peer", peer).Msg("received invalid missing message reply")
+		return
+	}
+	hash := messageHash(inner)
+	if !a.replay.Dupe(hash) {
+		a.stats.AddMsg(ACK, false)
+		a.entries.Receive(inner, a.clock.Now())
+		a.digestMessage(inner, hash)
+	}
+	a.repair.Ack(inner, hash, true)
+}
+
+func (a *App) sendDBStateRequest(id uint64) {
+	req := a.gen.CreateMessage(DBStateRequest)
+	encodeDBState(req, id)
+	a.n.DeliverMessage(a.n.RandomFlag(), req)
+	a.stats.AddSent(DBStateRequest, 1)
+}
+
+// retryDBStates sends timed out DBStateRequests to another random peer
+func (a *App) retryDBStates() {
+	ticker := time.NewTicker(dbstateInterval)
+	for range ticker.C {
+		for _, id := range a.dbstate.Due() {
+			a.sendDBStateRequest(id)
+		}
+	}
+}
+
+// requestMissing asks random peers for the messages this node is missing
+func (a *App) requestMissing() {
+	ticker := time.NewTicker(repairInterval)
+	for range ticker.C {
+		for _, hash := range a.repair.Due() {
+			req := a.gen.CreateMessage(MissingMsg)
+			encodeMissing(req, hash)
+			a.n.DeliverMessage(a.n.RandomFlag(), req)
+			a.stats.AddSent(MissingMsg, 1)
+		}
+	}
+}
+
+// relayMessage passes the message on according to the relay policy, with
+// this node added to the hop trailer
+func (a *App) relayMessage(msg []byte) bool {
+	targets := a.relay.Targets(a.n, msg[0])
+	if len(targets) == 0 {
+		return false
+	}
+	next := nextHop(msg, a.id)
+	for _, t := range targets {
+		a.n.DeliverMessage(t, next)
+	}
+	return true
+}
+
+func (a *App) handleAnnounce(peer string, msg []byte) {
+	var ann Announce
+	if err := decodeControl(msg, &ann); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid announcement")
+		return
+	}
+	a.cluster.Update(ann)
+}
+
+func (a *App) handleLoadControl(peer string, msg []byte) {
+	var la LoadAssignment
+	if err := decodeControl(msg, &la); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid load assignment")
+		return
+	}
+	log.Info().Str("origin", la.Origin).Bool("enable", la.Enable).Int("share", la.Shares[a.n.Name()]).Msg("received load assignment")
+	a.cluster.SetShares(la.Shares)
+	a.applyAssignment(la)
+}
+
+func (a *App) handleRoleControl(peer string, msg []byte) {
+	var ra RoleAssignment
+	if err := decodeControl(msg, &ra); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid role assignment")
+		return
+	}
+	a.auth.Assign(a.n.Name(), ra)
+	role, id := a.auth.Role()
+	log.Info().Int("feds", len(ra.Feds)).Int("audits", len(ra.Audits)).Str("role", role).Int("identity", id).Msg("received role assignment")
+}
+
+func (a *App) handleClockControl(peer string, msg []byte) {
+	var cs ClockSync
+	if err := decodeControl(msg, &cs); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid clock sync")
+		return
+	}
+	a.clock.Sync(cs)
+}
+
+func (a *App) handleCostControl(peer string, msg []byte) {
+	var ca CostAssignment
+	if err := decodeControl(msg, &ca); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid cost assignment")
+		return
+	}
+	if err := a.cost.Set(ca.Spec); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("unable to apply cost assignment")
+	}
+}
+
+var errNotLaunched = fmt.Errorf("the network isn't enabled yet")
+
+// isLaunched is true once the app has a network to send to
+func (a *App) isLaunched() bool {
+	return atomic.LoadInt32(&a.launched) == 1
+}
+
+// SetCost changes the simulated processing cost of messages. If all is set,
+// the cost model is applied to every node in the network.
+func (a *App) SetCost(spec string, all bool) error {
+	if all && !a.isLaunched() {
+		return errNotLaunched
+	}
+	if err := a.cost.Set(spec); err != nil {
+		return err
+	}
+	if all {
+		ca := CostAssignment{Time: time.Now().UnixNano(), Spec: spec}
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(CostControl, ca))
+	}
+	return nil
+}
+
+// Cost returns the spec of the current cost model
+func (a *App) Cost() string {
+	return a.cost.Spec()
+}
+
+func (a *App) handleRelayControl(peer string, msg []byte) {
+	var ra RelayAssignment
+	if err := decodeControl(msg, &ra); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid relay assignment")
+		return
+	}
+	if ra.Node != "" && ra.Node != a.n.Name() {
+		return
+	}
+	if ra.Fanout != 0 {
+		if err := a.setFanout(ra.Fanout); err != nil {
+			log.Warn().Err(err).Str("peer", peer).Msg("unable to apply fanout")
 		}
+		return
+	}
+	if err := a.relay.Set(ra.Policy, ra.Params); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("unable to apply relay assignment")
+		return
+	}
+	log.Info().Str("policy", ra.Policy).Msg("relay policy changed")
+}
+
+// SetRelay changes the relay policy. If all is set, the policy is applied to
+// every node in the network.
+func (a *App) SetRelay(policy, params string, all bool) error {
+	if all && !a.isLaunched() {
+		return errNotLaunched
+	}
+	if err := a.relay.Set(policy, params); err != nil {
+		return err
+	}
+	if all {
+		ra := RelayAssignment{Time: time.Now().UnixNano(), Policy: policy, Params: params}
+		a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(RelayControl, ra))
+	}
+	return nil
+}
+
+// setFanout changes how many peers this node broadcasts to. Libraries that
+// can change their fanout at runtime (p2p1) get the new fanout and the flood
+// policy, so every broadcast goes through the library. The others (p2p2) fall
+// back to the app level "fanout" relay policy. A negative fanout restores the
+// library's fanout from the start, the relay policy has to be restored
+// separately.
+func (a *App) setFanout(fanout int) error {
+	if fanout < 0 {
+		a.n.SetFanout(0)
+		return nil
+	}
+	if a.n.SetFanout(fanout) {
+		return a.relay.Set("flood", "")
+	}
+	return a.relay.Set("fanout", fmt.Sprintf("*=%d", fanout))
+}
+
+// SetFanout sets the broadcast fanout of every node in the network, see setFanout
+func (a *App) SetFanout(fanout int) error {
+	if !a.isLaunched() {
+		return errNotLaunched
+	}
+	if err := a.setFanout(fanout); err != nil {
+		return err
+	}
+	ra := RelayAssignment{Time: time.Now().UnixNano(), Fanout: fanout}
+	a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(RelayControl, ra))
+	return nil
+}
 
+// setNodeRelay changes the relay policy of a single node
+func (a *App) setNodeRelay(node, policy, params string) error {
+	if node == a.n.Name() {
+		return a.relay.Set(policy, params)
 	}
+	ra := RelayAssignment{Time: time.Now().UnixNano(), Node: node, Policy: policy, Params: params}
+	a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(RelayControl, ra))
+	return nil
+}
+
+// Relay returns the name and params of the current relay policy
+func (a *App) Relay() (string, string) {
+	return a.relay.Policy()
+}
+
+// SetClockMaster makes this node broadcast its block and minute boundaries
+// for every other node to follow
+func (a *App) SetClockMaster(master bool) {
+	a.clock.SetMaster(master)
+}
+
+// AssignRoles picks the federated and audit servers among the active nodes
+// and tells the network about it
+func (a *App) AssignRoles(mode string, feds, audits int, spec string) error {
+	if !a.isLaunched() {
+		return errNotLaunched
+	}
+	ra, err := AssignRoles(mode, feds, audits, a.cluster.Active(), spec)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Str("mode", mode).Int("feds", len(ra.Feds)).Int("audits", len(ra.Audits)).Msg("assigning roles")
+	a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(RoleControl, ra))
+	a.auth.Assign(a.n.Name(), ra)
+	return nil
+}
+
+// announce lets the rest of the network know this node exists
+func (a *App) announce() {
+	ticker := time.NewTicker(announceInterval)
+	for range ticker.C {
+		auth := a.auth.Stats()
+		clock := a.clock.Stats()
+		received, unique := a.stats.loadCounts()
+		relay, relayParams := a.relay.Policy()
+		metrics := a.n.Metrics()
+		a.stats.mtx.RLock()
+		ann := Announce{
+			Time:          time.Now().UnixNano(),
+			Node:          a.n.Name(),
+			ID:            a.id,
+			GenTarget:     a.stats.GenTarget,
+			GenEPS:        a.stats.GenEPS,
+			Role:          auth.Role,
+			EOMComplete:   auth.Complete,
+			EOMIncomplete: auth.Incomplete,
+			Height:        clock.Height,
+			Minute:        clock.Minute,
+			ClockSynced:   clock.Synced,
+			ClockOffset:   clock.Offset,
+			Originated:    atomic.LoadUint64(&a.stats.Originated),
+			Received:      received,
+			Unique:        unique,
+			BytesUp:       metrics.BytesUp,
+			Digests:       a.digests.Settled(clock.Height, clock.Minute),
+			Trace:         a.tracer.Last(),
+			Peers:         a.neighbors.Resolve(a.n.Peers()),
+			FirstPeer:     a.FirstPeer(),
+			Relay:         relay,
+			RelayParams:   relayParams,
+		}
+		a.stats.mtx.RUnlock()
+
+		a.cluster.Update(ann)
+		a.n.DeliverMessage(a.n.BroadcastFlag(), encodeControl(NodeAnnounce, ann))
+	}
+}
+
+// Nodes returns all nodes this node knows about
+func (a *App) Nodes() []NodeInfo {
+	return a.cluster.Nodes()
 }
 
 func (a *App) calculateStats() {
 	ticker := time.NewTicker(time.Second)
 	for range ticker.C {
 		a.stats.mtx.Lock()
-		a.stats.EPS = a.stats.EPSCount
-		a.stats.EPSCount = 0
-		a.stats.TPS = a.stats.TPSCount
-		a.stats.TPSCount = 0
+		a.stats.EPS = atomic.SwapUint64(&a.stats.EPSCount, 0)
+		a.stats.TPS = atomic.SwapUint64(&a.stats.TPSCount, 0)
+		a.stats.GenEPS = atomic.SwapUint64(&a.stats.GenCount, 0)
+		a.stats.Workers = a.workerStats()
+		a.entries.Expire()
 		a.stats.mtx.Unlock()
 	}
 }
 
 func (a *App) Launch(n network.Network) {
 	a.n = n
+	atomic.StoreInt32(&a.launched, 1)
+
+	a.mtx.Lock()
+	a.minuteStart = a.clock.Epoch()
+	a.mtx.Unlock()
 
 	go a.generateLoad()
 	go a.calculateStats()
-	for i := 0; i < workers; i++ {
-		go a.worker()
-	}
+	go a.announce()
+	go a.adaptFanout()
+	go a.requestMissing()
+	go a.retryDBStates()
+	go a.sayHello()
+	go a.watchFirstPeer(time.Now())
+	a.startWorkers()
 
-	ticker := time.NewTicker(minuteDuration)
-	for range ticker.C {
+	for {
+		timer := time.NewTimer(time.Until(a.clock.NextMinute()))
+		select {
+		case <-timer.C:
+		case <-a.clock.Changed():
+			timer.Stop()
+		}
+
+		now := time.Now()
+		height, minute := a.clock.At(now)
 		a.mtx.Lock()
-		a.Minute++
-		if a.Minute >= minutesPerBlock {
-			a.Height++
-			a.Minute = 0
+		if height == a.Height && minute == a.Minute {
+			a.mtx.Unlock()
+			continue
 		}
+		a.minuteStart = now
+		a.Height = height
+		a.Minute = minute
 		a.mtx.Unlock()
 
-		if a.generate {
+		if a.clock.Master() {
+			cs := ClockSync{Time: time.Now().UnixNano(), Epoch: a.clock.Epoch().UnixNano()}
+			a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(ClockControl, cs))
+		}
+
+		if a.generate && a.origin {
 			a.sendEOMs()
 		}
+		a.sendAuthority()
 	}
 }
 
-func (a *App) Settings() (bool, int, int, int) {
+func (a *App) Settings() (bool, int, int, int, string) {
 	a.mtx.RLock()
 	defer a.mtx.RUnlock()
-	return a.generate, a.eps, a.feds, a.audits
+	return a.generate, a.eps, a.feds, a.audits, a.arrival
 }
 
 func (a *App) sendEOMs() {
@@ -298,47 +874,88 @@ func (a *App) sendEOMs() {
 	if a.Minute == 0 {
 		typ = DBSig
 	}
-	for i := 0; i < a.feds; i++ {
-		a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(typ))
-	}
-	for i := 0; i < a.audits; i++ {
-		a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(Heartbeat))
+	if !a.auth.Active() {
+		for i := 0; i < a.feds; i++ {
+			a.n.DeliverMessage(a.n.RandomFlag(), a.stamp(a.gen.CreateMessage(typ)))
+		}
+		for i := 0; i < a.audits; i++ {
+			a.n.DeliverMessage(a.n.RandomFlag(), a.stamp(a.gen.CreateMessage(Heartbeat)))
+		}
 	}
 
 	if a.Minute == 0 && rand.Float64() < dbstateLikelihood {
-		a.n.DeliverMessage(a.n.RandomFlag(), a.gen.CreateMessage(DBStateRequest))
+		id := rand.Uint64()
+		a.dbstate.Request(id)
+		a.sendDBStateRequest(id)
 	}
 }
 
-func (a *App) ApplyLoad(generate bool, eps, feds, audits int) {
+// sendAuthority sends this node's own EOM, DBSig, or Heartbeat if it has a role
+func (a *App) sendAuthority() {
+	role, id := a.auth.Role()
+	if role == "" {
+		return
+	}
+
+	a.mtx.RLock()
+	height, minute := a.Height, a.Minute
+	a.mtx.RUnlock()
+
+	typ := Heartbeat
+	if role == "fed" {
+		typ = EOM
+		if minute == 0 {
+			typ = DBSig
+		}
+		a.auth.AddEOM(height, minute, id)
+	}
+	a.n.DeliverMessage(a.n.BroadcastFlag(), a.stamp(a.gen.CreateAuthorityMessage(typ, height, minute, id)))
+}
+
+// ApplyLoad starts or stops the load generator of this node only
+func (a *App) ApplyLoad(generate bool, eps, feds, audits int, arrival string) {
+	a.applyLoad(generate, true, eps, feds, audits, arrival, rampStep)
+}
+
+func (a *App) applyLoad(generate, origin bool, eps, feds, audits int, arrival string, step int) {
+	if generate && eps <= 0 {
+		// a share of zero, this node doesn't take part in the load test
+		generate = false
+	}
+
 	a.mtx.Lock()
 	if a.generate && generate {
+		a.mtx.Unlock()
 		log.Error().Msg("loadtest still running")
 		return
 	}
 	a.generate = generate
+	a.origin = origin
 	a.eps = eps
 	a.feds = feds
 	a.audits = audits
+	a.arrival = arrival
 	a.mtx.Unlock()
 
 	if generate {
-		log.Info().Int("eps", eps).Int("feds", feds).Int("audits", audits).Msg("setting load generator to ramp up to eps")
+		log.Info().Int("eps", eps).Int("feds", feds).Int("audits", audits).Str("arrival", arrival).Msg("setting load generator to ramp up to eps")
 		go func() {
-			if eps < 500 {
-				return
-			}
-			a.loadchange <- 1
-			load := 500
-			ticker := time.NewTicker(time.Second * 30)
-			for range ticker.C {
-				a.loadchange <- load
-				load += 500
-				if load > eps {
-					log.Info().Msg("load generator done")
-					break
+			if eps < step {
+				a.loadchange <- eps
+			} else {
+				a.loadchange <- 1
+				load := step
+				ticker := time.NewTicker(rampInterval)
+				for range ticker.C {
+					a.loadchange <- load
+					load += step
+					if load > eps {
+						break
+					}
 				}
+				ticker.Stop()
 			}
+			log.Info().Msg("load generator done")
 			a.mtx.Lock()
 			a.generate = false
 			a.mtx.Unlock()
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestApp_StatsSnapshot(t *testing.T) {
+	a, _ := newLoopbackApp(false)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				a.stats.AddMsg(ACK, i%2 == 0)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		s := a.Stats()
+		s.Waste(int(ACK))
+	}
+	wg.Wait()
+
+	s := a.Stats()
+	if s.Messages[ACK] != 4000 || s.NonDupeMessages[ACK] != 2000 {
+		t.Errorf("snapshot has %d messages, %d unique, want 4000, 2000", s.Messages[ACK], s.NonDupeMessages[ACK])
+	}
+	a.stats.AddMsg(ACK, false)
+	if s.Messages[ACK] != 4000 {
+		t.Errorf("snapshot changed with the counters")
+	}
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Arrival decides how many entries the load generator sends in each tick
+type Arrival interface {
+	// Count returns the number of entries to send in a tick of the given length.
+	// eps is the requested average rate, sinceMinute is the time elapsed since
+	// the start of the current minute.
+	Count(eps float64, tick, sinceMinute time.Duration) int
+}
+
+var validArrivals = []string{"constant", "poisson", "onoff", "surge"}
+
+// ArrivalNames returns the names of all available arrival processes
+func ArrivalNames() []string {
+	return validArrivals
+}
+
+// NewArrival creates the arrival process with the given name
+func NewArrival(name string) (Arrival, error) {
+	switch name {
+	case "", "constant":
+		return new(ConstantArrival), nil
+	case "poisson":
+		return new(PoissonArrival), nil
+	case "onoff":
+		return &OnOffArrival{On: onoffOn, Off: onoffOff}, nil
+	case "surge":
+		return &SurgeArrival{Window: surgeWindow, Factor: surgeFactor, Minute: minuteDuration}, nil
+	}
+	return nil, fmt.Errorf("unknown arrival process \"%s\"", name)
+}
+
+// ConstantArrival sends the same amount every tick, carrying fractions over
+// to the next tick
+type ConstantArrival struct {
+	accumulator float64
+}
+
+func (c *ConstantArrival) Count(eps float64, tick, sinceMinute time.Duration) int {
+	c.accumulator += eps * tick.Seconds()
+	n := int(c.accumulator)
+	c.accumulator -= float64(n)
+	return n
+}
+
+// PoissonArrival draws the amount of every tick from a poisson distribution
+type PoissonArrival struct{}
+
+func (p *PoissonArrival) Count(eps float64, tick, sinceMinute time.Duration) int {
+	return poisson(eps * tick.Seconds())
+}
+
+// poisson samples a poisson distributed value with mean lambda.
+// large means are approximated by a normal distribution.
+func poisson(lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		n := math.Round(lambda + rand.NormFloat64()*math.Sqrt(lambda))
+		if n < 0 {
+			return 0
+		}
+		return int(n)
+	}
+
+	l := math.Exp(-lambda)
+	k := 0
+	p := 1.0
+	for {
+		p *= rand.Float64()
+		if p <= l {
+			return k
+		}
+		k++
+	}
+}
+
+// OnOffArrival alternates between sending bursts for the On duration and
+// staying silent for the Off duration. The rate during bursts is raised so the
+// average matches the requested eps.
+type OnOffArrival struct {
+	On, Off time.Duration
+
+	elapsed time.Duration
+	burst   ConstantArrival
+}
+
+func (o *OnOffArrival) Count(eps float64, tick, sinceMinute time.Duration) int {
+	period := o.On + o.Off
+	if period <= 0 || o.On <= 0 {
+		return o.burst.Count(eps, tick, sinceMinute)
+	}
+
+	pos := o.elapsed % period
+	o.elapsed += tick
+	if pos >= o.On {
+		return 0
+	}
+	return o.burst.Count(eps*float64(period)/float64(o.On), tick, sinceMinute)
+}
+
+// SurgeArrival sends Factor times the requested rate during the first Window
+// of every minute, mirroring the rush of messages at block boundaries. The
+// rest of the minute is slowed down so the average matches the requested eps.
+type SurgeArrival struct {
+	Window time.Duration
+	Factor float64
+	Minute time.Duration
+
+	rate ConstantArrival
+}
+
+func (s *SurgeArrival) Count(eps float64, tick, sinceMinute time.Duration) int {
+	if s.Minute <= 0 || s.Window <= 0 {
+		return s.rate.Count(eps, tick, sinceMinute)
+	}
+
+	frac := float64(s.Window) / float64(s.Minute)
+	if frac >= 1 {
+		return s.rate.Count(eps, tick, sinceMinute)
+	}
+
+	factor := s.Factor
+	if sinceMinute%s.Minute >= s.Window {
+		// f*m + (1-f)*r = 1
+		factor = (1 - frac*s.Factor) / (1 - frac)
+		if factor < 0 {
+			factor = 0
+		}
+	}
+	return s.rate.Count(eps*factor, tick, sinceMinute)
+}
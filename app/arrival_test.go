@@ -0,0 +1,36 @@
+package app
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestArrival_Count(t *testing.T) {
+	const EPS = 1000.0
+	const TICK = time.Millisecond * 10
+	const DURATION = time.Minute * 10
+
+	for _, name := range ArrivalNames() {
+		t.Run(name, func(t *testing.T) {
+			arrival, err := NewArrival(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			total := 0
+			for elapsed := time.Duration(0); elapsed < DURATION; elapsed += TICK {
+				total += arrival.Count(EPS, TICK, elapsed%minuteDuration)
+			}
+
+			rate := float64(total) / DURATION.Seconds()
+			if diff := math.Abs(rate-EPS) / EPS; diff > 0.01 {
+				t.Errorf("rate = %.2f, want %.2f, diff %.4f", rate, EPS, diff)
+			}
+		})
+	}
+
+	if _, err := NewArrival("foo"); err == nil {
+		t.Errorf("NewArrival(foo) did not return an error")
+	}
+}
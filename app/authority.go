@@ -0,0 +1,267 @@
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var validRoleModes = []string{"host", "auto", "list"}
+
+// RoleModeNames returns the ways the authority set can be assigned.
+// "host" has the load generating node send all EOMs and Heartbeats itself.
+func RoleModeNames() []string {
+	return validRoleModes
+}
+
+// RoleAssignment is sent by the host to tell nodes which of them are
+// federated and audit servers. The position in the list is the identity.
+type RoleAssignment struct {
+	Time   int64
+	Feds   []string
+	Audits []string
+}
+
+// AssignRoles picks the federated and audit nodes.
+//
+//	host: nobody has a role
+//	auto: the first nodes become feds, the following ones audits
+//	list: the spec contains lines of name=fed or name=audit
+func AssignRoles(mode string, feds, audits int, nodes []string, spec string) (RoleAssignment, error) {
+	ra := RoleAssignment{Time: time.Now().UnixNano()}
+	switch mode {
+	case "", "host":
+	case "auto":
+		if feds < 0 || audits < 0 {
+			return ra, fmt.Errorf("the number of feds and audits can't be negative")
+		}
+		if feds+audits > len(nodes) {
+			return ra, fmt.Errorf("not enough nodes for %d feds and %d audits, only %d active", feds, audits, len(nodes))
+		}
+		ra.Feds = append(ra.Feds, nodes[:feds]...)
+		ra.Audits = append(ra.Audits, nodes[feds:feds+audits]...)
+	case "list":
+		for _, line := range strings.Split(spec, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			split := strings.SplitN(line, "=", 2)
+			if len(split) != 2 {
+				return ra, fmt.Errorf("invalid line \"%s\", expected name=fed or name=audit", line)
+			}
+			name, role := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+			node := ""
+			for _, n := range nodes {
+				if matchNode(n, name) {
+					node = n
+					break
+				}
+			}
+			if node == "" {
+				return ra, fmt.Errorf("no active node named \"%s\"", name)
+			}
+			switch role {
+			case "fed":
+				ra.Feds = append(ra.Feds, node)
+			case "audit":
+				ra.Audits = append(ra.Audits, node)
+			default:
+				return ra, fmt.Errorf("unknown role \"%s\"", role)
+			}
+		}
+	default:
+		return ra, fmt.Errorf("unknown role mode \"%s\"", mode)
+	}
+	return ra, nil
+}
+
+// authority messages (EOM, DBSig, Heartbeat) carry the height, minute, and
+// identity of the sender after the message type
+const authorityHeaderLen = 8
+
+func encodeAuthority(msg []byte, height, minute, identity int) {
+	binary.BigEndian.PutUint32(msg[1:], uint32(height))
+	msg[5] = byte(minute)
+	binary.BigEndian.PutUint16(msg[6:], uint16(identity))
+}
+
+func decodeAuthority(msg []byte) (height, minute, identity int, ok bool) {
+	if len(msg) < authorityHeaderLen {
+		return 0, 0, 0, false
+	}
+	return int(binary.BigEndian.Uint32(msg[1:])), int(msg[5]), int(binary.BigEndian.Uint16(msg[6:])), true
+}
+
+type minuteKey struct {
+	Height, Minute int
+}
+
+func (mk minuteKey) before(o minuteKey) bool {
+	return mk.Height < o.Height || (mk.Height == o.Height && mk.Minute < o.Minute)
+}
+
+type eomMinute struct {
+	first, last time.Time
+	feds        map[int]bool
+}
+
+// AuthorityStats shows how well the EOMs of the feds reached this node
+type AuthorityStats struct {
+	Role        string
+	Identity    int
+	Feds        int
+	Audits      int
+	Complete    uint64
+	Incomplete  uint64
+	Late        uint64
+	LastMissing int
+	LastSpread  time.Duration
+}
+
+// Authority keeps track of this node's role and of the EOMs received for each
+// minute. A minute is complete if the EOMs of all feds arrived before the
+// first EOM of the next minute.
+type Authority struct {
+	mtx     sync.Mutex
+	roles   RoleAssignment
+	fed     int
+	audit   int
+	minutes map[minuteKey]*eomMinute
+	latest  minuteKey
+
+	complete    uint64
+	incomplete  uint64
+	late        uint64
+	lastMissing int
+	lastSpread  time.Duration
+}
+
+func NewAuthority() *Authority {
+	au := new(Authority)
+	au.fed = -1
+	au.audit = -1
+	au.minutes = make(map[minuteKey]*eomMinute)
+	return au
+}
+
+// Assign the roles and reset the measurements
+func (au *Authority) Assign(self string, ra RoleAssignment) {
+	au.mtx.Lock()
+	defer au.mtx.Unlock()
+	au.roles = ra
+	au.fed = -1
+	au.audit = -1
+	for i, n := range ra.Feds {
+		if n == self {
+			au.fed = i
+		}
+	}
+	for i, n := range ra.Audits {
+		if n == self {
+			au.audit = i
+		}
+	}
+	au.minutes = make(map[minuteKey]*eomMinute)
+	au.latest = minuteKey{}
+	au.complete, au.incomplete, au.late = 0, 0, 0
+	au.lastMissing, au.lastSpread = 0, 0
+}
+
+// Active is true if the feds and audits are separate nodes
+func (au *Authority) Active() bool {
+	au.mtx.Lock()
+	defer au.mtx.Unlock()
+	return len(au.roles.Feds)+len(au.roles.Audits) > 0
+}
+
+// Role returns "fed", "audit", or "" along with the identity of this node
+func (au *Authority) Role() (string, int) {
+	au.mtx.Lock()
+	defer au.mtx.Unlock()
+	return au.role()
+}
+
+func (au *Authority) role() (string, int) {
+	if au.fed >= 0 {
+		return "fed", au.fed
+	}
+	if au.audit >= 0 {
+		return "audit", au.audit
+	}
+	return "", -1
+}
+
+// Receive records an EOM or DBSig
+func (au *Authority) Receive(msg []byte) {
+	height, minute, identity, ok := decodeAuthority(msg)
+	if !ok {
+		return
+	}
+	au.AddEOM(height, minute, identity)
+}
+
+// AddEOM records the EOM of a fed for the given minute
+func (au *Authority) AddEOM(height, minute, identity int) {
+	au.mtx.Lock()
+	defer au.mtx.Unlock()
+	if identity >= len(au.roles.Feds) {
+		return
+	}
+
+	key := minuteKey{height, minute}
+	if key.before(au.latest) {
+		if _, ok := au.minutes[key]; !ok {
+			au.late++
+			return
+		}
+	}
+
+	if au.latest.before(key) {
+		// a new minute started, everything before is final
+		for k, m := range au.minutes {
+			if k.before(key) {
+				au.finalize(m)
+				delete(au.minutes, k)
+			}
+		}
+		au.latest = key
+	}
+
+	m, ok := au.minutes[key]
+	if !ok {
+		m = &eomMinute{first: time.Now(), feds: make(map[int]bool)}
+		au.minutes[key] = m
+	}
+	m.last = time.Now()
+	m.feds[identity] = true
+}
+
+func (au *Authority) finalize(m *eomMinute) {
+	au.lastMissing = len(au.roles.Feds) - len(m.feds)
+	au.lastSpread = m.last.Sub(m.first)
+	if au.lastMissing == 0 {
+		au.complete++
+	} else {
+		au.incomplete++
+	}
+}
+
+func (au *Authority) Stats() AuthorityStats {
+	au.mtx.Lock()
+	defer au.mtx.Unlock()
+	role, id := au.role()
+	return AuthorityStats{
+		Role:        role,
+		Identity:    id,
+		Feds:        len(au.roles.Feds),
+		Audits:      len(au.roles.Audits),
+		Complete:    au.complete,
+		Incomplete:  au.incomplete,
+		Late:        au.late,
+		LastMissing: au.lastMissing,
+		LastSpread:  au.lastSpread,
+	}
+}
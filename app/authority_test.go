@@ -0,0 +1,55 @@
+package app
+
+import "testing"
+
+func TestAuthority_AddEOM(t *testing.T) {
+	au := NewAuthority()
+	au.Assign("fed1", RoleAssignment{Feds: []string{"fed0", "fed1", "fed2"}, Audits: []string{"audit0"}})
+
+	if role, id := au.Role(); role != "fed" || id != 1 {
+		t.Fatalf("Role() = %s %d, want fed 1", role, id)
+	}
+
+	// minute 1 complete, minute 2 missing one, minute 3 pending
+	for fed := 0; fed < 3; fed++ {
+		au.AddEOM(5, 1, fed)
+	}
+	au.AddEOM(5, 2, 0)
+	au.AddEOM(5, 2, 2)
+	au.AddEOM(5, 3, 0)
+	au.AddEOM(5, 2, 1) // minute 2 already over
+	au.AddEOM(5, 3, 7) // unknown fed
+
+	st := au.Stats()
+	if st.Complete != 1 || st.Incomplete != 1 || st.Late != 1 || st.LastMissing != 1 {
+		t.Errorf("Stats() = %+v, want 1 complete, 1 incomplete, 1 late, 1 missing", st)
+	}
+}
+
+func TestAssignRoles(t *testing.T) {
+	nodes := []string{"Node0-1", "Node1-2", "Node2-3"}
+
+	ra, err := AssignRoles("auto", 2, 1, nodes, "")
+	if err != nil || len(ra.Feds) != 2 || len(ra.Audits) != 1 || ra.Audits[0] != "Node2-3" {
+		t.Errorf("AssignRoles(auto) = %+v, %v", ra, err)
+	}
+
+	if _, err := AssignRoles("auto", 3, 1, nodes, ""); err == nil {
+		t.Errorf("AssignRoles(auto) with too few nodes did not return an error")
+	}
+	if _, err := AssignRoles("auto", -1, 2, nodes, ""); err == nil {
+		t.Errorf("AssignRoles(auto) with negative feds did not return an error")
+	}
+	if _, err := AssignRoles("auto", 2, -1, nodes, ""); err == nil {
+		t.Errorf("AssignRoles(auto) with negative audits did not return an error")
+	}
+
+	ra, err = AssignRoles("list", 0, 0, nodes, "Node2=fed\nNode0-1=audit")
+	if err != nil || len(ra.Feds) != 1 || ra.Feds[0] != "Node2-3" || ra.Audits[0] != "Node0-1" {
+		t.Errorf("AssignRoles(list) = %+v, %v", ra, err)
+	}
+
+	if _, err := AssignRoles("list", 0, 0, nodes, "Node1=king"); err == nil {
+		t.Errorf("AssignRoles(list) with unknown role did not return an error")
+	}
+}
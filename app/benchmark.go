@@ -0,0 +1,98 @@
+package app
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+// BenchmarkResult is the outcome of running the app against a loopback network
+type BenchmarkResult struct {
+	Duration   time.Duration
+	Pooled     bool
+	Workers    int
+	Entries    uint64
+	Sent       uint64
+	Processed  uint64
+	Dropped    uint64
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+func (br BenchmarkResult) perSecond(v uint64) float64 {
+	return float64(v) / br.Duration.Seconds()
+}
+
+func (br BenchmarkResult) String() string {
+	return fmt.Sprintf("duration=%s pooled=%v workers=%d entries/s=%.0f sent/s=%.0f processed/s=%.0f dropped=%d allocs/msg=%.2f bytes/msg=%.0f",
+		br.Duration, br.Pooled, br.Workers,
+		br.perSecond(br.Entries), br.perSecond(br.Sent), br.perSecond(br.Processed), br.Dropped,
+		float64(br.Allocs)/float64(br.Sent), float64(br.AllocBytes)/float64(br.Sent))
+}
+
+// newLoopbackApp creates an app that runs its workers against a loopback network
+func newLoopbackApp(pooled bool) (*App, *network.Loopback) {
+	lb := network.NewLoopback(loopbackCapacity)
+	a := NewApp()
+	a.gen.SetPooled(pooled)
+	a.n = lb
+	a.startWorkers()
+	return a, lb
+}
+
+// RunBenchmark generates entries as fast as possible for the given duration
+// and feeds them through the app's workers without any network.
+// The amount of processed messages is the ceiling of what this tool can handle.
+func RunBenchmark(duration time.Duration, pooled bool) BenchmarkResult {
+	a, lb := newLoopbackApp(pooled)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var entries uint64
+	stop := make(chan interface{})
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				a.SendRandomizedMessage()
+				atomic.AddUint64(&entries, 1)
+			}
+		}()
+	}
+
+	start := time.Now()
+	time.Sleep(duration)
+	close(stop)
+	wg.Wait()
+	for lb.Pending() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	sent, processed, dropped := lb.Counts()
+	return BenchmarkResult{
+		Duration:   elapsed,
+		Pooled:     pooled,
+		Workers:    workers,
+		Entries:    atomic.LoadUint64(&entries),
+		Sent:       sent,
+		Processed:  processed,
+		Dropped:    dropped,
+		Allocs:     after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+func benchmarkPipeline(b *testing.B, pooled bool) {
+	a, lb := newLoopbackApp(pooled)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.SendRandomizedMessage()
+		for lb.Pending() > loopbackCapacity/2 {
+			time.Sleep(time.Microsecond)
+		}
+	}
+	for lb.Pending() > 0 {
+		time.Sleep(time.Microsecond)
+	}
+	b.StopTimer()
+
+	_, _, dropped := lb.Counts()
+	if dropped > 0 {
+		b.Errorf("%d messages dropped", dropped)
+	}
+}
+
+func BenchmarkApp_Pipeline(b *testing.B)       { benchmarkPipeline(b, false) }
+func BenchmarkApp_PipelinePooled(b *testing.B) { benchmarkPipeline(b, true) }
+
+// BenchmarkApp_Workers measures how the processing of received messages scales
+// with the number of workers for the original and the sharded dedup
+func BenchmarkApp_Workers(b *testing.B) {
+	for _, dedup := range []string{"buckets", "sharded"} {
+		for _, workers := range []int{1, 2, 4, 8, 16} {
+			b.Run(fmt.Sprintf("%s/workers-%d", dedup, workers), func(b *testing.B) {
+				a := NewApp()
+				a.n = network.NewLoopback(loopbackCapacity)
+				if err := a.SetDedup(dedup); err != nil {
+					b.Fatal(err)
+				}
+				a.gen.SetPooled(true)
+				msgs := make([][]byte, b.N)
+				for i := range msgs {
+					msgs[i] = a.gen.CreateMessage(CommitEntry)
+				}
+
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for w := 0; w < workers; w++ {
+					wg.Add(1)
+					go func(w int) {
+						defer wg.Done()
+						for i := w; i < len(msgs); i += workers {
+							a.process("bench", msgs[i])
+						}
+					}(w)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func TestRunBenchmark(t *testing.T) {
+	res := RunBenchmark(time.Millisecond*200, true)
+	if res.Entries == 0 || res.Processed == 0 {
+		t.Errorf("benchmark did not process anything: %s", res)
+	}
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// BloomReplay stores hashes in a rotating list of bloom filters. Memory is
+// fixed no matter the load but there is a chance of false positives, which
+// grows with the number of messages per filter.
+//
+// The bits are picked directly from the sha256 hash, which is already
+// uniformly distributed, so at most 4 hash functions are possible.
+type BloomReplay struct {
+	interval time.Duration
+	size     uint
+	bits     uint64
+	hashes   int
+
+	stop    chan struct{}
+	mtx     sync.RWMutex
+	filters [][]uint64
+	added   []int
+}
+
+var _ Deduplicator = (*BloomReplay)(nil)
+
+func NewBloomReplay(interval time.Duration, size uint, bits uint64, hashes int) *BloomReplay {
+	b := new(BloomReplay)
+	b.interval = interval
+	b.size = size
+	b.bits = bits
+	if hashes > 4 {
+		hashes = 4
+	}
+	b.hashes = hashes
+	b.filters = [][]uint64{b.newFilter()}
+	b.added = []int{0}
+	b.stop = make(chan struct{})
+	go b.start()
+	return b
+}
+
+func (b *BloomReplay) newFilter() []uint64 {
+	return make([]uint64, (b.bits+63)/64)
+}
+
+func (b *BloomReplay) start() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+		b.mtx.Lock()
+		b.filters = append([][]uint64{b.newFilter()}, b.filters...)
+		b.added = append([]int{0}, b.added...)
+		if len(b.filters) > int(b.size) {
+			b.filters = b.filters[:b.size]
+			b.added = b.added[:b.size]
+		}
+		b.mtx.Unlock()
+	}
+}
+
+func (b *BloomReplay) positions(hash [32]byte) [4]uint64 {
+	var pos [4]uint64
+	for i := 0; i < b.hashes; i++ {
+		pos[i] = binary.LittleEndian.Uint64(hash[i*8:]) % b.bits
+	}
+	return pos
+}
+
+func (b *BloomReplay) Dupe(hash [32]byte) bool {
+	pos := b.positions(hash)
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for _, f := range b.filters {
+		found := true
+		for i := 0; i < b.hashes; i++ {
+			if f[pos[i]/64]&(1<<(pos[i]%64)) == 0 {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+
+	for i := 0; i < b.hashes; i++ {
+		b.filters[0][pos[i]/64] |= 1 << (pos[i] % 64)
+	}
+	b.added[0]++
+	return false
+}
+
+func (b *BloomReplay) Len() int {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	n := 0
+	for _, a := range b.added {
+		n += a
+	}
+	return n
+}
+
+// Memory is the size of the filters, which is exact
+func (b *BloomReplay) Memory() uint64 {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return uint64(len(b.filters)) * (b.bits + 63) / 64 * 8
+}
+
+func (b *BloomReplay) Stop() { close(b.stop) }
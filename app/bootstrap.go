@@ -0,0 +1,26 @@
+package app
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// watchFirstPeer records how long it took from launch until the network
+// library connected to the first peer. It runs once per process, losing all
+// peers later on or changing the seed fault doesn't start a new measurement.
+func (a *App) watchFirstPeer(launched time.Time) {
+	ticker := time.NewTicker(firstPeerPoll)
+	defer ticker.Stop()
+	for range ticker.C {
+		if len(a.n.Peers()) > 0 {
+			atomic.StoreInt64(&a.firstPeer, int64(time.Since(launched)))
+			return
+		}
+	}
+}
+
+// FirstPeer is the time from launch until the first peer connected, zero if
+// there is no peer yet
+func (a *App) FirstPeer() time.Duration {
+	return time.Duration(atomic.LoadInt64(&a.firstPeer))
+}
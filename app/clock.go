@@ -0,0 +1,152 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// ClockSync is broadcast by the clock master at every minute so all nodes
+// share the same block and minute boundaries
+type ClockSync struct {
+	Time  int64
+	Epoch int64
+}
+
+// ClockStats shows the state of this node's clock
+type ClockStats struct {
+	Height int
+	Minute int
+	Master bool
+	Synced bool
+	Offset time.Duration
+}
+
+// Clock calculates the current block height and minute relative to an epoch,
+// the start of block 0. Until the clock is synced, the epoch is the time the
+// clock was created and every node is on its own schedule.
+//
+// After syncing, the offset is the difference between the master's clock and
+// the local clock. The time a sync message spends travelling makes the offset
+// appear smaller than it is, so the largest recent sample is used.
+type Clock struct {
+	mtx     sync.RWMutex
+	master  bool
+	synced  bool
+	epoch   time.Time
+	offset  time.Duration
+	samples []time.Duration
+	changed chan bool
+}
+
+func NewClock() *Clock {
+	c := new(Clock)
+	c.epoch = time.Now()
+	c.changed = make(chan bool, 1)
+	return c
+}
+
+// Changed is signalled whenever the clock is synced
+func (c *Clock) Changed() <-chan bool {
+	return c.changed
+}
+
+// SetMaster makes this clock the one all other nodes sync to
+func (c *Clock) SetMaster(master bool) {
+	c.mtx.Lock()
+	c.master = master
+	c.mtx.Unlock()
+}
+
+func (c *Clock) Master() bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.master
+}
+
+// Now returns the current time on the master's clock
+func (c *Clock) Now() time.Time {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+// Epoch returns the start of block 0 in the master's time
+func (c *Clock) Epoch() time.Time {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.epoch
+}
+
+// Sync aligns the clock to the master's epoch
+func (c *Clock) Sync(cs ClockSync) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.master {
+		return
+	}
+
+	c.samples = append(c.samples, time.Unix(0, cs.Time).Sub(time.Now()))
+	if len(c.samples) > clockSamples {
+		c.samples = c.samples[len(c.samples)-clockSamples:]
+	}
+
+	c.offset = c.samples[0]
+	for _, s := range c.samples {
+		if s > c.offset {
+			c.offset = s
+		}
+	}
+	c.epoch = time.Unix(0, cs.Epoch)
+	c.synced = true
+
+	select {
+	case c.changed <- true:
+	default:
+	}
+}
+
+// position returns the minutes elapsed since the epoch at the local time t
+func (c *Clock) position(t time.Time) int {
+	elapsed := t.Add(c.offset).Sub(c.epoch)
+	if elapsed < 0 {
+		return 0
+	}
+	return int(elapsed / minuteDuration)
+}
+
+// At returns the height and minute at the local time t
+func (c *Clock) At(t time.Time) (int, int) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	pos := c.position(t)
+	return pos / minutesPerBlock, pos % minutesPerBlock
+}
+
+// MasterAt returns the height and minute at the master's time t
+func (c *Clock) MasterAt(t time.Time) (int, int) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	pos := c.position(t.Add(-c.offset))
+	return pos / minutesPerBlock, pos % minutesPerBlock
+}
+
+// NextMinute returns the local time the next minute starts
+func (c *Clock) NextMinute() time.Time {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	pos := c.position(time.Now())
+	return c.epoch.Add(-c.offset).Add(time.Duration(pos+1) * minuteDuration)
+}
+
+func (c *Clock) Stats() ClockStats {
+	height, minute := c.At(time.Now())
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return ClockStats{
+		Height: height,
+		Minute: minute,
+		Master: c.master,
+		Synced: c.synced,
+		Offset: c.offset,
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock_Sync(t *testing.T) {
+	c := NewClock()
+	epoch := time.Now().Add(-time.Hour)
+	for _, off := range []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 200 * time.Millisecond} {
+		c.Sync(ClockSync{Time: time.Now().Add(off).UnixNano(), Epoch: epoch.UnixNano()})
+	}
+	st := c.Stats()
+	if !st.Synced || st.Offset < 250*time.Millisecond || st.Offset > 300*time.Millisecond {
+		t.Errorf("offset = %s synced %v, want the largest sample of about 300ms", st.Offset, st.Synced)
+	}
+	if !c.Epoch().Equal(time.Unix(0, epoch.UnixNano())) {
+		t.Errorf("epoch = %s, want %s", c.Epoch(), epoch)
+	}
+	select {
+	case <-c.Changed():
+	default:
+		t.Errorf("sync did not signal a change")
+	}
+
+	// a large first sample is forgotten once clockSamples newer ones arrived
+	c = NewClock()
+	c.Sync(ClockSync{Time: time.Now().Add(time.Minute).UnixNano(), Epoch: epoch.UnixNano()})
+	for i := 0; i < clockSamples; i++ {
+		c.Sync(ClockSync{Time: time.Now().Add(-time.Second).UnixNano(), Epoch: epoch.UnixNano()})
+	}
+	if len(c.samples) != clockSamples {
+		t.Errorf("%d samples kept, want %d", len(c.samples), clockSamples)
+	}
+	if off := c.Stats().Offset; off > -900*time.Millisecond || off < -1100*time.Millisecond {
+		t.Errorf("offset = %s after trimming, want about -1s", off)
+	}
+
+	// the master ignores syncs
+	c = NewClock()
+	c.SetMaster(true)
+	c.Sync(ClockSync{Time: time.Now().Add(time.Minute).UnixNano(), Epoch: epoch.UnixNano()})
+	if st := c.Stats(); st.Synced || st.Offset != 0 {
+		t.Errorf("master synced to %s", st.Offset)
+	}
+}
+
+func TestClock_At(t *testing.T) {
+	epoch := time.Unix(1600000000, 0)
+	m := minuteDuration
+	tests := []struct {
+		name           string
+		offset         time.Duration
+		local          time.Time
+		height, minute int
+		masterH        int
+		masterM        int
+	}{
+		{"at epoch", 0, epoch, 0, 0, 0, 0},
+		{"before epoch", 0, epoch.Add(-5 * m), 0, 0, 0, 0},
+		{"block 1 minute 3", 0, epoch.Add(13*m + m/2), 1, 3, 1, 3},
+		{"master ahead", 2 * m, epoch.Add(13 * m), 1, 5, 1, 3},
+		{"master behind", -2 * m, epoch.Add(13 * m), 1, 1, 1, 3},
+		{"master behind before epoch", -2 * m, epoch.Add(m), 0, 0, 0, 1},
+		{"just before a boundary", 0, epoch.Add(20*m - time.Nanosecond), 1, 9, 1, 9},
+	}
+	for _, tt := range tests {
+		c := &Clock{epoch: epoch, offset: tt.offset}
+		if h, mi := c.At(tt.local); h != tt.height || mi != tt.minute {
+			t.Errorf("%s: At() = %d/%d, want %d/%d", tt.name, h, mi, tt.height, tt.minute)
+		}
+		// MasterAt reads the master's time, which doesn't depend on the offset
+		if h, mi := c.MasterAt(tt.local); h != tt.masterH || mi != tt.masterM {
+			t.Errorf("%s: MasterAt() = %d/%d, want %d/%d", tt.name, h, mi, tt.masterH, tt.masterM)
+		}
+	}
+}
+
+func TestClock_NextMinute(t *testing.T) {
+	m := minuteDuration
+	for _, offset := range []time.Duration{0, 5 * time.Second, -5 * time.Second} {
+		// two and a half minutes into the schedule on the master's clock
+		c := &Clock{epoch: time.Now().Add(offset).Add(-2*m - m/2), offset: offset}
+		if d := time.Until(c.NextMinute()); d < m/2-time.Second || d > m/2 {
+			t.Errorf("offset %s: next minute in %s, want %s", offset, d, m/2)
+		}
+
+		// before the epoch the first minute starts at the epoch
+		c = &Clock{epoch: time.Now().Add(offset).Add(m / 2), offset: offset}
+		if d := time.Until(c.NextMinute()); d < m+m/2-time.Second || d > m+m/2 {
+			t.Errorf("offset %s before epoch: next minute in %s, want %s", offset, d, m+m/2)
+		}
+	}
+}
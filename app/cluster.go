@@ -0,0 +1,145 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NodeInfo is what a node knows about another node in the test network
+type NodeInfo struct {
+	Name      string
+	ID        uint64
+	LastSeen  time.Time
+	GenTarget uint64
+	GenEPS    uint64
+	Share     int
+
+	Role          string
+	EOMComplete   uint64
+	EOMIncomplete uint64
+
+	Height      int
+	Minute      int
+	ClockSynced bool
+	ClockOffset time.Duration
+
+	Originated uint64
+	Received   uint64
+	Unique     uint64
+	BytesUp    uint64
+
+	Digests []MinuteDigest
+	Trace   TraceArrival
+	Peers   []string
+
+	FirstPeer time.Duration
+
+	Relay       string
+	RelayParams string
+}
+
+// Active is true if the node has been heard from recently
+func (ni NodeInfo) Active() bool {
+	return time.Since(ni.LastSeen) < nodeTimeout
+}
+
+// Cluster keeps track of all the nodes that announced themselves
+type Cluster struct {
+	mtx   sync.RWMutex
+	nodes map[string]*NodeInfo
+}
+
+func NewCluster() *Cluster {
+	c := new(Cluster)
+	c.nodes = make(map[string]*NodeInfo)
+	return c
+}
+
+func (c *Cluster) get(name string) *NodeInfo {
+	ni, ok := c.nodes[name]
+	if !ok {
+		ni = &NodeInfo{Name: name}
+		c.nodes[name] = ni
+	}
+	return ni
+}
+
+// Update the node's information from an announcement
+func (c *Cluster) Update(ann Announce) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	ni := c.get(ann.Node)
+	ni.LastSeen = time.Now()
+	ni.ID = ann.ID
+	ni.GenTarget = ann.GenTarget
+	ni.GenEPS = ann.GenEPS
+	ni.Role = ann.Role
+	ni.EOMComplete = ann.EOMComplete
+	ni.EOMIncomplete = ann.EOMIncomplete
+	ni.Height = ann.Height
+	ni.Minute = ann.Minute
+	ni.ClockSynced = ann.ClockSynced
+	ni.ClockOffset = ann.ClockOffset
+	ni.Originated = ann.Originated
+	ni.Received = ann.Received
+	ni.Unique = ann.Unique
+	ni.BytesUp = ann.BytesUp
+	ni.Digests = ann.Digests
+	ni.Trace = ann.Trace
+	ni.Peers = ann.Peers
+	ni.FirstPeer = ann.FirstPeer
+	ni.Relay = ann.Relay
+	ni.RelayParams = ann.RelayParams
+}
+
+// SetShares records the eps assigned to each node
+func (c *Cluster) SetShares(shares map[string]int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, ni := range c.nodes {
+		ni.Share = 0
+	}
+	for name, eps := range shares {
+		c.get(name).Share = eps
+	}
+}
+
+// Active returns the sorted names of all nodes that have been heard from recently
+func (c *Cluster) Active() []string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	names := make([]string, 0, len(c.nodes))
+	for name, ni := range c.nodes {
+		if ni.Active() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Names maps the short ids used in recorded paths to node names
+func (c *Cluster) Names() map[uint16]string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	names := make(map[uint16]string, len(c.nodes))
+	for name, ni := range c.nodes {
+		names[shortID(ni.ID)] = name
+	}
+	return names
+}
+
+// Nodes returns a copy of all known nodes, sorted by name
+func (c *Cluster) Nodes() []NodeInfo {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	nodes := make([]NodeInfo, 0, len(c.nodes))
+	for _, ni := range c.nodes {
+		nodes = append(nodes, *ni)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
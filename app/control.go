@@ -0,0 +1,84 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Control messages coordinate the nodes of the test network. Unlike the
+// simulated factom messages, they carry a json encoded payload after the
+// message type.
+
+// Announce is periodically sent by every node to let the others know it exists
+type Announce struct {
+	Time          int64
+	Node          string
+	ID            uint64
+	GenTarget     uint64
+	GenEPS        uint64
+	Role          string
+	EOMComplete   uint64
+	EOMIncomplete uint64
+	Height        int
+	Minute        int
+	ClockSynced   bool
+	ClockOffset   time.Duration
+	Originated    uint64
+	Received      uint64
+	Unique        uint64
+	BytesUp       uint64
+	Digests       []MinuteDigest
+	Trace         TraceArrival
+	Peers         []string
+	FirstPeer     time.Duration
+	Relay         string
+	RelayParams   string
+}
+
+// LoadAssignment is sent by the host to start or stop the load generators of
+// the entire network. Only the Origin node sends EOMs and Heartbeats.
+type LoadAssignment struct {
+	Time    int64
+	Enable  bool
+	Origin  string
+	Arrival string
+	Feds    int
+	Audits  int
+	Total   int
+	Shares  map[string]int
+}
+
+// CostAssignment is sent by the host to apply the same processing cost model
+// to all nodes
+type CostAssignment struct {
+	Time int64
+	Spec string
+}
+
+// RelayAssignment is sent by the host to use the same relay policy on all
+// nodes, or only on Node if it is set. If Fanout is set, the nodes set their
+// broadcast fanout instead, see setFanout.
+type RelayAssignment struct {
+	Time   int64
+	Node   string
+	Policy string
+	Params string
+	Fanout int
+}
+
+func encodeControl(typ byte, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// only happens with unsupported types, which is a programming error
+		panic(err)
+	}
+	return append([]byte{typ}, data...)
+}
+
+func decodeControl(msg []byte, v interface{}) error {
+	if len(msg) < 2 {
+		return fmt.Errorf("control message too short")
+	}
+	return json.Unmarshal(msg[1:], v)
+}
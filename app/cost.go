@@ -0,0 +1,189 @@
+package app
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cost simulates the work of validating a message
+type Cost interface {
+	Apply()
+}
+
+// FixedCost waits the same amount for every message
+type FixedCost struct{ Delay time.Duration }
+
+func (c FixedCost) Apply() { time.Sleep(c.Delay) }
+
+// UniformCost waits a random amount between Min and Max
+type UniformCost struct{ Min, Max time.Duration }
+
+func (c UniformCost) Apply() {
+	time.Sleep(c.Min + time.Duration(rand.Int63n(int64(c.Max-c.Min)+1)))
+}
+
+// NormalCost waits a normally distributed amount, never less than zero
+type NormalCost struct{ Mean, StdDev time.Duration }
+
+func (c NormalCost) Apply() {
+	d := float64(c.Mean) + rand.NormFloat64()*float64(c.StdDev)
+	if d > 0 {
+		time.Sleep(time.Duration(d))
+	}
+}
+
+// ExpCost waits an exponentially distributed amount, which has a long tail of
+// slow messages
+type ExpCost struct{ Mean time.Duration }
+
+func (c ExpCost) Apply() {
+	time.Sleep(time.Duration(rand.ExpFloat64() * float64(c.Mean)))
+}
+
+// CPUCost keeps the cpu busy hashing instead of sleeping, so slow validation
+// also competes with the network library for cpu time
+type CPUCost struct{ Duration time.Duration }
+
+func (c CPUCost) Apply() {
+	var buf [32]byte
+	start := time.Now()
+	for time.Since(start) < c.Duration {
+		for i := 0; i < 64; i++ {
+			buf = sha256.Sum256(buf[:])
+		}
+	}
+}
+
+// parseCost parses a model of the form "name:params"
+//
+//	fixed:2ms
+//	uniform:1ms-5ms
+//	normal:2ms,500us
+//	exp:2ms
+//	cpu:1ms
+func parseCost(model string) (Cost, error) {
+	split := strings.SplitN(model, ":", 2)
+	if len(split) != 2 {
+		return nil, fmt.Errorf("invalid cost \"%s\", expected model:params", model)
+	}
+
+	// only uniform separates its parameters with "-", so a leading "-" of
+	// the other models is read as a negative duration
+	raw := split[1]
+	if split[0] == "uniform" {
+		raw = strings.Replace(raw, "-", ",", 1)
+	}
+	params := strings.Split(raw, ",")
+	durations := make([]time.Duration, len(params))
+	for i, p := range params {
+		d, err := time.ParseDuration(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("negative duration in \"%s\"", model)
+		}
+		durations[i] = d
+	}
+
+	want := 1
+	if split[0] == "uniform" || split[0] == "normal" {
+		want = 2
+	}
+	if len(durations) != want {
+		return nil, fmt.Errorf("cost \"%s\" needs %d parameters", split[0], want)
+	}
+
+	switch split[0] {
+	case "fixed":
+		return FixedCost{durations[0]}, nil
+	case "uniform":
+		if durations[1] < durations[0] {
+			return nil, fmt.Errorf("uniform max is smaller than min")
+		}
+		return UniformCost{durations[0], durations[1]}, nil
+	case "normal":
+		return NormalCost{durations[0], durations[1]}, nil
+	case "exp":
+		return ExpCost{durations[0]}, nil
+	case "cpu":
+		return CPUCost{durations[0]}, nil
+	}
+	return nil, fmt.Errorf("unknown cost model \"%s\"", split[0])
+}
+
+// CostModel holds the processing cost of each message type
+type CostModel struct {
+	mtx   sync.RWMutex
+	spec  string
+	costs [MESSAGEMAX]Cost
+}
+
+// Set parses lines of "Type=model:params". The type "*" applies the cost to
+// all simulated factom messages that don't have their own line.
+func (cm *CostModel) Set(spec string) error {
+	var costs [MESSAGEMAX]Cost
+	var all Cost
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid line \"%s\", expected Type=model:params", line)
+		}
+		cost, err := parseCost(strings.TrimSpace(split[1]))
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSpace(split[0])
+		if name == "*" {
+			all = cost
+			continue
+		}
+		typ, ok := MessageType(name)
+		if !ok {
+			return fmt.Errorf("unknown message type \"%s\"", name)
+		}
+		costs[typ] = cost
+	}
+
+	if all != nil {
+		for typ := ACK; typ < StartRecording; typ++ {
+			if costs[typ] == nil {
+				costs[typ] = all
+			}
+		}
+	}
+
+	cm.mtx.Lock()
+	cm.spec = spec
+	cm.costs = costs
+	cm.mtx.Unlock()
+	return nil
+}
+
+func (cm *CostModel) Spec() string {
+	cm.mtx.RLock()
+	defer cm.mtx.RUnlock()
+	return cm.spec
+}
+
+// Apply simulates the processing cost of the message type
+func (cm *CostModel) Apply(typ byte) {
+	if typ >= MESSAGEMAX {
+		return
+	}
+	cm.mtx.RLock()
+	cost := cm.costs[typ]
+	cm.mtx.RUnlock()
+	if cost != nil {
+		cost.Apply()
+	}
+}
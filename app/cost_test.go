@@ -0,0 +1,102 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseCost(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Cost
+		err   bool
+	}{
+		{"fixed:2ms", FixedCost{2 * time.Millisecond}, false},
+		{"uniform:1ms-5ms", UniformCost{time.Millisecond, 5 * time.Millisecond}, false},
+		{"uniform:1ms,5ms", UniformCost{time.Millisecond, 5 * time.Millisecond}, false},
+		{"uniform: 2ms - 2ms ", UniformCost{2 * time.Millisecond, 2 * time.Millisecond}, false},
+		{"normal:2ms,500us", NormalCost{2 * time.Millisecond, 500 * time.Microsecond}, false},
+		{"exp:2ms", ExpCost{2 * time.Millisecond}, false},
+		{"cpu:1ms", CPUCost{time.Millisecond}, false},
+		{"fixed", nil, true},
+		{"fixed:", nil, true},
+		{"fixed:2", nil, true},
+		{"fixed:-2ms", nil, true},
+		{"fixed:2ms,3ms", nil, true},
+		{"exp:-2ms", nil, true},
+		{"normal:2ms", nil, true},
+		{"normal:2ms-500us", nil, true},
+		{"uniform:5ms-1ms", nil, true},
+		{"uniform:-1ms-5ms", nil, true},
+		{"uniform:1ms--5ms", nil, true},
+		{"uniform:1ms", nil, true},
+		{"bogus:1ms", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCost(tt.model)
+		if (err != nil) != tt.err {
+			t.Errorf("parseCost(%q) error = %v, want error %v", tt.model, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCost(%q) = %#v, want %#v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCostModel_Set(t *testing.T) {
+	tests := []struct {
+		spec string
+		want map[byte]Cost
+		err  bool
+	}{
+		{"", map[byte]Cost{ACK: nil, EOM: nil}, false},
+		{"*=fixed:1ms", map[byte]Cost{ACK: FixedCost{time.Millisecond}, Transaction: FixedCost{time.Millisecond}, MissingMsg: FixedCost{time.Millisecond}, StartRecording: nil, RelayControl: nil}, false},
+		{"EOM=exp:2ms\n\n *=fixed:1ms ", map[byte]Cost{EOM: ExpCost{2 * time.Millisecond}, ACK: FixedCost{time.Millisecond}}, false},
+		{"ACK=cpu:1ms", map[byte]Cost{ACK: CPUCost{time.Millisecond}, EOM: nil}, false},
+		{"ACK", nil, true},
+		{"ACK=fixed", nil, true},
+		{"Bogus=fixed:1ms", nil, true},
+		{"*=fixed:1ms\nEOM=fixed:-1ms", nil, true},
+	}
+	for _, tt := range tests {
+		cm := new(CostModel)
+		cm.Set("*=fixed:9ms")
+		err := cm.Set(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("Set(%q) error = %v, want error %v", tt.spec, err, tt.err)
+			continue
+		}
+		if err != nil {
+			if cm.Spec() != "*=fixed:9ms" {
+				t.Errorf("Set(%q) failed but changed the spec to %q", tt.spec, cm.Spec())
+			}
+			continue
+		}
+		if cm.Spec() != tt.spec {
+			t.Errorf("Spec() = %q, want %q", cm.Spec(), tt.spec)
+		}
+		for typ, want := range tt.want {
+			if !reflect.DeepEqual(cm.costs[typ], want) {
+				t.Errorf("Set(%q) cost of %d = %#v, want %#v", tt.spec, typ, cm.costs[typ], want)
+			}
+		}
+	}
+}
+
+func TestApp_SetCostBeforeLaunch(t *testing.T) {
+	a := NewApp()
+	if err := a.SetCost("*=fixed:1ms", true); err == nil {
+		t.Errorf("SetCost for all nodes before launch did not return an error")
+	}
+	if a.Cost() != "" {
+		t.Errorf("SetCost for all nodes before launch changed the cost to %q", a.Cost())
+	}
+	if err := a.SetCost("*=fixed:1ms", false); err != nil {
+		t.Errorf("SetCost for this node before launch: %v", err)
+	}
+	if err := a.SetRelay("flood", "", true); err == nil {
+		t.Errorf("SetRelay for all nodes before launch did not return an error")
+	}
+}
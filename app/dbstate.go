@@ -0,0 +1,140 @@
+package app
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// DBStateRequest and DBStateReply carry the id of the request after the type
+const dbstateHeaderLen = 9
+
+func encodeDBState(msg []byte, id uint64) {
+	binary.BigEndian.PutUint64(msg[1:], id)
+}
+
+func decodeDBState(msg []byte) (uint64, bool) {
+	if len(msg) < dbstateHeaderLen {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(msg[1:]), true
+}
+
+// DBStateStats shows how the DBStateRequests of this node were answered
+type DBStateStats struct {
+	Sent        uint64
+	Retries     uint64
+	Answered    uint64
+	Failed      uint64
+	Pending     int
+	Replies     uint64
+	Unsolicited uint64
+	AvgLatency  time.Duration
+	MaxLatency  time.Duration
+}
+
+// RepliesPerRequest is the average amount of replies to answered requests
+func (ds DBStateStats) RepliesPerRequest() float64 {
+	if ds.Answered == 0 {
+		return 0
+	}
+	return float64(ds.Replies) / float64(ds.Answered)
+}
+
+type dbstateRequest struct {
+	first    time.Time
+	last     time.Time
+	attempts int
+	replies  int
+}
+
+// DBStates keeps track of the outstanding DBStateRequests of this node.
+// Requests are sent to a single peer, which is expected to reply directly.
+// Answered requests are kept around for dbstateTimeout to count late and
+// extra replies.
+type DBStates struct {
+	mtx      sync.Mutex
+	requests map[uint64]*dbstateRequest
+
+	stats        DBStateStats
+	totalLatency time.Duration
+}
+
+func NewDBStates() *DBStates {
+	d := new(DBStates)
+	d.requests = make(map[uint64]*dbstateRequest)
+	return d
+}
+
+// Request records a new outgoing request
+func (d *DBStates) Request(id uint64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	now := time.Now()
+	d.requests[id] = &dbstateRequest{first: now, last: now, attempts: 1}
+	d.stats.Sent++
+}
+
+// Reply records a reply to the request with the given id
+func (d *DBStates) Reply(id uint64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	req, ok := d.requests[id]
+	if !ok {
+		d.stats.Unsolicited++
+		return
+	}
+	req.replies++
+	d.stats.Replies++
+	if req.replies == 1 {
+		latency := time.Since(req.first)
+		d.stats.Answered++
+		d.totalLatency += latency
+		if latency > d.stats.MaxLatency {
+			d.stats.MaxLatency = latency
+		}
+	}
+}
+
+// Due returns the ids of unanswered requests that timed out and should be
+// sent to another peer. Requests fail after dbstateAttempts.
+func (d *DBStates) Due() []uint64 {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	now := time.Now()
+	var due []uint64
+	for id, req := range d.requests {
+		if now.Sub(req.last) < dbstateTimeout {
+			continue
+		}
+		switch {
+		case req.replies > 0:
+			delete(d.requests, id)
+		case req.attempts >= dbstateAttempts:
+			delete(d.requests, id)
+			d.stats.Failed++
+		default:
+			req.last = now
+			req.attempts++
+			d.stats.Retries++
+			due = append(due, id)
+		}
+	}
+	return due
+}
+
+func (d *DBStates) Stats() DBStateStats {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	ds := d.stats
+	for _, req := range d.requests {
+		if req.replies == 0 {
+			ds.Pending++
+		}
+	}
+	if ds.Answered > 0 {
+		ds.AvgLatency = d.totalLatency / time.Duration(ds.Answered)
+	}
+	return ds
+}
@@ -0,0 +1,37 @@
+package app
+
+import "testing"
+
+func TestDBStates(t *testing.T) {
+	old := dbstateTimeout
+	dbstateTimeout = 0
+	defer func() { dbstateTimeout = old }()
+
+	d := NewDBStates()
+	d.Request(1)
+	d.Request(2)
+
+	d.Reply(1)
+	d.Reply(1)
+	d.Reply(3)
+
+	due := d.Due()
+	if len(due) != 1 || due[0] != 2 {
+		t.Fatalf("due = %v, want [2]", due)
+	}
+
+	for i := 0; i < dbstateAttempts; i++ {
+		d.Due()
+	}
+
+	ds := d.Stats()
+	if ds.Sent != 2 || ds.Answered != 1 || ds.Replies != 2 || ds.Unsolicited != 1 {
+		t.Errorf("unexpected stats %+v", ds)
+	}
+	if ds.Failed != 1 || ds.Pending != 0 {
+		t.Errorf("request 2 should have failed, stats %+v", ds)
+	}
+	if ds.RepliesPerRequest() != 2 {
+		t.Errorf("replies per request = %f, want 2", ds.RepliesPerRequest())
+	}
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+// Deduplicator decides whether a message has been seen before
+type Deduplicator interface {
+	// Dupe checks if the hash has been seen before and records it
+	Dupe(hash [32]byte) bool
+	// Len is the number of hashes stored, probabilistic filters report the
+	// number of hashes added
+	Len() int
+	// Memory is an estimate of the bytes used, see mapEntryBytes
+	Memory() uint64
+	// Stop ends the background rotation, the deduplicator isn't used anymore
+	Stop()
+}
+
+var validDedups = []string{"buckets", "lru", "bloom", "timewheel", "sharded"}
+
+// DedupNames returns the available duplicate detection strategies
+func DedupNames() []string {
+	return validDedups
+}
+
+// NewDeduplicator creates the duplicate detection strategy with the given name.
+// All strategies remember messages for roughly replayInterval * replaySize.
+func NewDeduplicator(name string) (Deduplicator, error) {
+	switch name {
+	case "", "buckets":
+		return NewReplay(replayInterval, replaySize), nil
+	case "lru":
+		return NewLRUReplay(lruSize), nil
+	case "bloom":
+		return NewBloomReplay(replayInterval, replaySize, bloomBits, bloomHashes), nil
+	case "timewheel":
+		return NewTimeWheel(replayInterval, replaySize), nil
+	case "sharded":
+		return NewShardedTimeWheel(replayInterval, replaySize, dedupShards), nil
+	}
+	return nil, fmt.Errorf("unknown dedup strategy \"%s\"", name)
+}
+
+// mapEntryBytes is a rough estimate of the bytes per entry of a map with keys
+// and values of the given sizes. Go doesn't expose the size of a map, so this
+// assumes buckets of 8 keys, 8 values, their tophash bytes, and an overflow
+// pointer that are 6.5/8 full on average. Other Go versions lay out maps
+// differently, the estimate only shows the order of magnitude.
+func mapEntryBytes(key, value uintptr) uint64 {
+	bucket := 8 + 8*key + 8*value + unsafe.Sizeof(uintptr(0))
+	return uint64(float64(bucket) / 6.5)
+}
+
+// ReplayStats shows the efficiency of the duplicate detection
+type ReplayStats struct {
+	Strategy       string
+	Entries        int
+	Memory         uint64
+	Checks         uint64
+	Dupes          uint64
+	Sampled        uint64
+	FalsePositives uint64
+	FalseNegatives uint64
+}
+
+func (rs ReplayStats) MemoryF() string {
+	return network.PrettyBytes(rs.Memory)
+}
+
+// FalsePositiveRate is the share of sampled new messages that were mistaken for duplicates
+func (rs ReplayStats) FalsePositiveRate() float64 {
+	if rs.Sampled == 0 {
+		return 0
+	}
+	return float64(rs.FalsePositives) / float64(rs.Sampled)
+}
+
+// AuditedDedup wraps a strategy and keeps an exact record of a sample of the
+// hashes to count how often the strategy is wrong. A hash is sampled if the
+// last byte is zero, which is 1 in 256 messages.
+type AuditedDedup struct {
+	name string
+	d    Deduplicator
+
+	checks uint64
+	dupes  uint64
+
+	stop chan struct{}
+
+	mtx            sync.Mutex
+	shadow         map[[32]byte]time.Time
+	sampled        uint64
+	falsePositives uint64
+	falseNegatives uint64
+}
+
+func NewAuditedDedup(name string, d Deduplicator) *AuditedDedup {
+	ad := new(AuditedDedup)
+	ad.name = name
+	ad.d = d
+	ad.shadow = make(map[[32]byte]time.Time)
+	ad.stop = make(chan struct{})
+	go ad.cleanup()
+	return ad
+}
+
+func (ad *AuditedDedup) cleanup() {
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ad.stop:
+			return
+		case <-ticker.C:
+		}
+		limit := time.Now().Add(-replayInterval * time.Duration(replaySize))
+		ad.mtx.Lock()
+		for h, t := range ad.shadow {
+			if t.Before(limit) {
+				delete(ad.shadow, h)
+			}
+		}
+		ad.mtx.Unlock()
+	}
+}
+
+func (ad *AuditedDedup) Dupe(hash [32]byte) bool {
+	dupe := ad.d.Dupe(hash)
+	atomic.AddUint64(&ad.checks, 1)
+	if dupe {
+		atomic.AddUint64(&ad.dupes, 1)
+	}
+
+	if hash[31] == 0 {
+		ad.mtx.Lock()
+		_, seen := ad.shadow[hash]
+		if !seen {
+			ad.shadow[hash] = time.Now()
+			ad.sampled++
+		}
+		if dupe && !seen {
+			ad.falsePositives++
+		} else if !dupe && seen {
+			ad.falseNegatives++
+		}
+		ad.mtx.Unlock()
+	}
+	return dupe
+}
+
+func (ad *AuditedDedup) Len() int       { return ad.d.Len() }
+func (ad *AuditedDedup) Memory() uint64 { return ad.d.Memory() }
+
+// Stop ends the cleanup of the sample and the rotation of the strategy
+func (ad *AuditedDedup) Stop() {
+	close(ad.stop)
+	ad.d.Stop()
+}
+
+func (ad *AuditedDedup) Stats() ReplayStats {
+	ad.mtx.Lock()
+	defer ad.mtx.Unlock()
+	return ReplayStats{
+		Strategy:       ad.name,
+		Entries:        ad.d.Len(),
+		Memory:         ad.d.Memory(),
+		Checks:         atomic.LoadUint64(&ad.checks),
+		Dupes:          atomic.LoadUint64(&ad.dupes),
+		Sampled:        ad.sampled,
+		FalsePositives: ad.falsePositives,
+		FalseNegatives: ad.falseNegatives,
+	}
+}
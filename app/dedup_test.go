@@ -0,0 +1,104 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDeduplicator_Dupe(t *testing.T) {
+	const HASHES = 10000
+
+	for _, name := range DedupNames() {
+		t.Run(name, func(t *testing.T) {
+			d, err := NewDeduplicator(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf [8]byte
+			for i := 0; i < HASHES; i++ {
+				binary.BigEndian.PutUint64(buf[:], uint64(i))
+				hash := sha256.Sum256(buf[:])
+				if d.Dupe(hash) {
+					t.Errorf("new hash %d reported as dupe", i)
+				}
+				if !d.Dupe(hash) {
+					t.Errorf("hash %d not reported as dupe", i)
+				}
+			}
+
+			if d.Len() != HASHES {
+				t.Errorf("Len() = %d, want %d", d.Len(), HASHES)
+			}
+			if d.Memory() == 0 {
+				t.Errorf("Memory() = 0")
+			}
+		})
+	}
+}
+
+func BenchmarkDeduplicator_Dupe(b *testing.B) {
+	for _, name := range DedupNames() {
+		for _, workers := range []int{1, 2, 4, 8, 16} {
+			b.Run(fmt.Sprintf("%s/workers-%d", name, workers), func(b *testing.B) {
+				d, err := NewDeduplicator(name)
+				if err != nil {
+					b.Fatal(err)
+				}
+				hashes := make([][32]byte, b.N)
+				for i := range hashes {
+					hashes[i] = sha256.Sum256([]byte(fmt.Sprint(i)))
+				}
+
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for w := 0; w < workers; w++ {
+					wg.Add(1)
+					go func(w int) {
+						defer wg.Done()
+						for i := w; i < len(hashes); i += workers {
+							d.Dupe(hashes[i])
+						}
+					}(w)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func TestApp_SetDedupStopsOld(t *testing.T) {
+	a := NewApp()
+	old := a.replay
+	if err := a.SetDedup("timewheel"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-old.stop:
+	default:
+		t.Errorf("replaced dedup was not stopped")
+	}
+	select {
+	case <-old.d.(*Replay).stop:
+	default:
+		t.Errorf("strategy of the replaced dedup was not stopped")
+	}
+	select {
+	case <-a.replay.stop:
+		t.Errorf("new dedup was stopped")
+	default:
+	}
+}
+
+func Test_mapEntryBytes(t *testing.T) {
+	// 8 tophash + 8 * 32 byte keys + 8 bool values + 8 byte overflow pointer
+	if got := mapEntryBytes(32, 1); got != 43 {
+		t.Errorf("mapEntryBytes(32, 1) = %d, want 43", got)
+	}
+	if timeWheelEntryBytes <= 32 || lruEntryBytes <= timeWheelEntryBytes {
+		t.Errorf("entry estimates time wheel %d, lru %d don't fit the layouts", timeWheelEntryBytes, lruEntryBytes)
+	}
+}
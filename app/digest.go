@@ -0,0 +1,161 @@
+package app
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+)
+
+// MinuteDigest summarizes the set of load messages a node saw for a minute.
+// The digest is the xor of the message hashes, so it doesn't depend on the
+// order the messages arrived in.
+type MinuteDigest struct {
+	Height int
+	Minute int
+	Count  uint64
+	Digest uint64
+}
+
+func (md MinuteDigest) key() minuteKey {
+	return minuteKey{md.Height, md.Minute}
+}
+
+func (mk minuteKey) position() int {
+	return mk.Height*minutesPerBlock + mk.Minute
+}
+
+// Digests keeps the digests of the last digestHistory minutes. Messages are
+// sorted into the minute they were created in, not the one they arrived in.
+type Digests struct {
+	mtx     sync.Mutex
+	minutes map[minuteKey]*MinuteDigest
+	latest  int
+}
+
+func NewDigests() *Digests {
+	d := new(Digests)
+	d.minutes = make(map[minuteKey]*MinuteDigest)
+	return d
+}
+
+// Add the hash of a message created in the given minute
+func (d *Digests) Add(height, minute int, hash [32]byte) {
+	key := minuteKey{height, minute}
+	pos := key.position()
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if pos <= d.latest-digestHistory {
+		return
+	}
+
+	md, ok := d.minutes[key]
+	if !ok {
+		md = &MinuteDigest{Height: height, Minute: minute}
+		d.minutes[key] = md
+	}
+	md.Count++
+	md.Digest ^= binary.BigEndian.Uint64(hash[:8])
+
+	if pos > d.latest {
+		d.latest = pos
+		for k := range d.minutes {
+			if k.position() <= d.latest-digestHistory {
+				delete(d.minutes, k)
+			}
+		}
+	}
+}
+
+// Settled returns the digests of the minutes that ended at least a minute
+// before the current one, so late messages had time to arrive
+func (d *Digests) Settled(height, minute int) []MinuteDigest {
+	cutoff := minuteKey{height, minute}.position() - 1
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var settled []MinuteDigest
+	for k, md := range d.minutes {
+		if k.position() < cutoff {
+			settled = append(settled, *md)
+		}
+	}
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].key().position() < settled[j].key().position()
+	})
+	return settled
+}
+
+// DigestDiff is a node that saw a different set of messages than the majority
+type DigestDiff struct {
+	Node    string
+	Count   uint64
+	Missing int64
+}
+
+// MinuteConsistency compares the digests of all nodes for one minute
+type MinuteConsistency struct {
+	Height int
+	Minute int
+	Nodes  int
+	Agree  int
+	Count  uint64
+	Differ []DigestDiff
+}
+
+// Consistent is true if every node saw the same set of messages
+func (mc MinuteConsistency) Consistent() bool {
+	return len(mc.Differ) == 0
+}
+
+// compareDigests finds the set of messages most nodes agree on for each
+// minute and lists the nodes that differ from it, newest minute first.
+// Missing is how many fewer messages the node saw than the majority, which is
+// negative if it saw more.
+func compareDigests(nodes []NodeInfo) []MinuteConsistency {
+	type variant struct {
+		digest uint64
+		count  uint64
+	}
+	minutes := make(map[minuteKey]map[string]variant)
+	for _, ni := range nodes {
+		if !ni.Active() {
+			continue
+		}
+		for _, md := range ni.Digests {
+			m, ok := minutes[md.key()]
+			if !ok {
+				m = make(map[string]variant)
+				minutes[md.key()] = m
+			}
+			m[ni.Name] = variant{md.Digest, md.Count}
+		}
+	}
+
+	result := make([]MinuteConsistency, 0, len(minutes))
+	for key, m := range minutes {
+		votes := make(map[variant]int)
+		var majority variant
+		for _, v := range m {
+			votes[v]++
+			if votes[v] > votes[majority] || (votes[v] == votes[majority] && v.count > majority.count) {
+				majority = v
+			}
+		}
+
+		mc := MinuteConsistency{Height: key.Height, Minute: key.Minute, Nodes: len(m), Agree: votes[majority], Count: majority.count}
+		for name, v := range m {
+			if v != majority {
+				mc.Differ = append(mc.Differ, DigestDiff{Node: name, Count: v.count, Missing: int64(majority.count) - int64(v.count)})
+			}
+		}
+		sort.Slice(mc.Differ, func(i, j int) bool {
+			return mc.Differ[i].Node < mc.Differ[j].Node
+		})
+		result = append(result, mc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return minuteKey{result[i].Height, result[i].Minute}.position() > minuteKey{result[j].Height, result[j].Minute}.position()
+	})
+	return result
+}
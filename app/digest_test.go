@@ -0,0 +1,62 @@
+package app
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+func TestDigests_Order(t *testing.T) {
+	a, b := NewDigests(), NewDigests()
+	hashes := [][32]byte{sha256.Sum256([]byte{1}), sha256.Sum256([]byte{2}), sha256.Sum256([]byte{3})}
+	for i := range hashes {
+		a.Add(0, 1, hashes[i])
+		b.Add(0, 1, hashes[len(hashes)-1-i])
+	}
+
+	da, db := a.Settled(0, 3), b.Settled(0, 3)
+	if len(da) != 1 || len(db) != 1 {
+		t.Fatalf("expected one settled minute, got %d and %d", len(da), len(db))
+	}
+	if da[0] != db[0] || da[0].Count != 3 {
+		t.Errorf("digests differ: %+v %+v", da[0], db[0])
+	}
+
+	if s := a.Settled(0, 2); len(s) != 0 {
+		t.Errorf("minute 1 settled during minute 2")
+	}
+}
+
+func TestDigests_History(t *testing.T) {
+	d := NewDigests()
+	d.Add(0, 0, sha256.Sum256([]byte{1}))
+	d.Add(5, 0, sha256.Sum256([]byte{2}))
+	d.Add(0, 1, sha256.Sum256([]byte{3}))
+	if len(d.minutes) != 1 {
+		t.Errorf("old minutes were kept: %d", len(d.minutes))
+	}
+}
+
+func Test_compareDigests(t *testing.T) {
+	now := time.Now()
+	same := MinuteDigest{Height: 1, Minute: 2, Count: 10, Digest: 0xabc}
+	nodes := []NodeInfo{
+		{Name: "a", LastSeen: now, Digests: []MinuteDigest{same, {Height: 1, Minute: 3, Count: 4, Digest: 1}}},
+		{Name: "b", LastSeen: now, Digests: []MinuteDigest{same}},
+		{Name: "c", LastSeen: now, Digests: []MinuteDigest{{Height: 1, Minute: 2, Count: 7, Digest: 0xdef}}},
+	}
+
+	mc := compareDigests(nodes)
+	if len(mc) != 2 {
+		t.Fatalf("got %d minutes, want 2", len(mc))
+	}
+	if mc[0].Minute != 3 || !mc[0].Consistent() {
+		t.Errorf("unexpected newest minute %+v", mc[0])
+	}
+	if mc[1].Agree != 2 || mc[1].Nodes != 3 || len(mc[1].Differ) != 1 {
+		t.Fatalf("unexpected minute %+v", mc[1])
+	}
+	if diff := mc[1].Differ[0]; diff.Node != "c" || diff.Missing != 3 {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var validDistributions = []string{"local", "even", "weighted", "list"}
+
+// DistributionNames returns the ways the load can be split between nodes.
+// "local" only runs the generator on this node.
+func DistributionNames() []string {
+	return validDistributions
+}
+
+// parseShareSpec parses lines of "name=value". Names match either the full
+// node name or the name given to the node without the node id.
+func parseShareSpec(spec string) (map[string]float64, error) {
+	values := make(map[string]float64)
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid line \"%s\", expected name=value", line)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(split[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("negative value for \"%s\"", split[0])
+		}
+		values[strings.TrimSpace(split[0])] = v
+	}
+	return values, nil
+}
+
+// matchNode checks if the name refers to the node, either by full name or by
+// the name without the node id
+func matchNode(node, name string) bool {
+	return node == name || strings.HasPrefix(node, name+"-")
+}
+
+func lookupSpec(values map[string]float64, node string) (float64, bool) {
+	if v, ok := values[node]; ok {
+		return v, true
+	}
+	for name, v := range values {
+		if matchNode(node, name) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// AssignShares splits the total eps between the nodes.
+//
+//	even: every node gets the same amount
+//	weighted: the spec contains a weight for nodes, unlisted nodes have a weight of 1
+//	list: the spec contains the eps for nodes, unlisted nodes get nothing. total is ignored
+func AssignShares(mode string, total int, nodes []string, spec string) (map[string]int, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes to distribute load to")
+	}
+
+	shares := make(map[string]int)
+	switch mode {
+	case "even":
+		for i, n := range nodes {
+			shares[n] = total / len(nodes)
+			if i < total%len(nodes) {
+				shares[n]++
+			}
+		}
+	case "weighted":
+		values, err := parseShareSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		weights := make([]float64, len(nodes))
+		sum := 0.0
+		for i, n := range nodes {
+			weights[i] = 1
+			if w, ok := lookupSpec(values, n); ok {
+				weights[i] = w
+			}
+			sum += weights[i]
+		}
+		if sum <= 0 {
+			return nil, fmt.Errorf("all weights are zero")
+		}
+		assigned := 0
+		for i, n := range nodes {
+			shares[n] = int(float64(total) * weights[i] / sum)
+			assigned += shares[n]
+		}
+		// hand out the rounding leftovers
+		for i := 0; assigned < total; i = (i + 1) % len(nodes) {
+			if weights[i] > 0 {
+				shares[nodes[i]]++
+				assigned++
+			}
+		}
+	case "list":
+		values, err := parseShareSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if v, ok := lookupSpec(values, n); ok {
+				shares[n] = int(v)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution \"%s\"", mode)
+	}
+	return shares, nil
+}
+
+// DistributeLoad assigns a share of the load to every active node in the
+// network and tells them to start their generators. This node sends the EOMs
+// and Heartbeats.
+func (a *App) DistributeLoad(generate bool, eps, feds, audits int, arrival, mode, spec string) error {
+	if mode == "" || mode == "local" {
+		a.ApplyLoad(generate, eps, feds, audits, arrival)
+		return nil
+	}
+
+	shares, err := AssignShares(mode, eps, a.cluster.Active(), spec)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, s := range shares {
+		total += s
+	}
+
+	la := LoadAssignment{
+		Time:    time.Now().UnixNano(),
+		Enable:  generate,
+		Origin:  a.n.Name(),
+		Arrival: arrival,
+		Feds:    feds,
+		Audits:  audits,
+		Total:   total,
+		Shares:  shares,
+	}
+
+	log.Info().Str("mode", mode).Int("total", total).Int("nodes", len(shares)).Msg("distributing load")
+	a.n.DeliverMessage(a.n.FullBroadcastFlag(), encodeControl(LoadControl, la))
+	a.cluster.SetShares(shares)
+	a.applyAssignment(la)
+	return nil
+}
+
+func (a *App) applyAssignment(la LoadAssignment) {
+	share := la.Shares[a.n.Name()]
+	origin := la.Origin == a.n.Name()
+
+	feds, audits := 0, 0
+	if origin {
+		feds, audits = la.Feds, la.Audits
+	}
+
+	// ramp up in the same amount of steps as the total load
+	step := rampStep
+	if la.Total > 0 {
+		step = share * rampStep / la.Total
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	a.applyLoad(la.Enable, origin, share, feds, audits, la.Arrival, step)
+}
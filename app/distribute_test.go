@@ -0,0 +1,78 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAssignShares(t *testing.T) {
+	nodes := []string{"Node0-1", "Node1-2", "Node2-3"}
+
+	tests := []struct {
+		name    string
+		mode    string
+		total   int
+		spec    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"even", "even", 1000, "", map[string]int{"Node0-1": 334, "Node1-2": 333, "Node2-3": 333}, false},
+		{"weighted", "weighted", 1000, "Node0=2\nNode2-3=0", map[string]int{"Node0-1": 667, "Node1-2": 333, "Node2-3": 0}, false},
+		{"weighted zero", "weighted", 1000, "Node0=0\nNode1=0\nNode2=0", nil, true},
+		{"list", "list", 0, "Node1=250\n\nNode2-3 = 50", map[string]int{"Node1-2": 250, "Node2-3": 50}, false},
+		{"list invalid", "list", 0, "Node1 250", nil, true},
+		{"unknown", "foo", 1000, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AssignShares(tt.mode, tt.total, nodes, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssignShares() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssignShares() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApp_applyLoadZeroShare(t *testing.T) {
+	a := NewApp()
+	loads := make(chan int, 10)
+	go func() {
+		for l := range a.loadchange {
+			loads <- l
+		}
+	}()
+
+	a.applyLoad(true, false, 0, 0, 0, "constant", 10)
+	if generate, _, _, _, _ := a.Settings(); generate {
+		t.Fatalf("a share of zero started the load generator")
+	}
+	if l := <-loads; l != 0 {
+		t.Errorf("a share of zero set the load to %d", l)
+	}
+
+	// a share below the ramp step is applied at once and the next
+	// assignment is accepted afterwards
+	a.applyLoad(true, false, 5, 0, 0, "constant", 10)
+	if l := <-loads; l != 5 {
+		t.Errorf("load = %d, want 5", l)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if generate, _, _, _, _ := a.Settings(); !generate {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("load generator still running after reaching its share")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	a.applyLoad(true, false, 8, 0, 0, "constant", 10)
+	if l := <-loads; l != 8 {
+		t.Errorf("later assignment set load %d, want 8", l)
+	}
+}
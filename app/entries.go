@@ -0,0 +1,186 @@
+package app
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The parts of an entry created by the load generator. A transaction only
+// has the first two.
+const (
+	partCommit byte = 1 << iota
+	partCommitAck
+	partReveal
+	partRevealAck
+
+	entryParts       = partCommit | partCommitAck | partReveal | partRevealAck
+	transactionParts = partCommit | partCommitAck
+)
+
+// Every part of an entry carries a tag after the ACK header: the origin id,
+// the entry's sequence number, the part, the parts that make up the entry,
+// and the time of creation on the master's clock
+const (
+	entryTagStart = ackHeaderLen
+	entryTagLen   = entryTagStart + 26
+)
+
+// EntryTag identifies the entry a message belongs to
+type EntryTag struct {
+	Origin  uint64
+	Seq     uint64
+	Want    byte
+	Created time.Time
+}
+
+func (et EntryTag) encode(msg []byte, part byte) {
+	binary.BigEndian.PutUint64(msg[entryTagStart:], et.Origin)
+	binary.BigEndian.PutUint64(msg[entryTagStart+8:], et.Seq)
+	msg[entryTagStart+16] = part
+	msg[entryTagStart+17] = et.Want
+	binary.BigEndian.PutUint64(msg[entryTagStart+18:], uint64(et.Created.UnixNano()))
+}
+
+func decodeEntryTag(msg []byte) (et EntryTag, part byte, ok bool) {
+	if len(msg) < entryTagLen {
+		return et, 0, false
+	}
+	et.Origin = binary.BigEndian.Uint64(msg[entryTagStart:])
+	et.Seq = binary.BigEndian.Uint64(msg[entryTagStart+8:])
+	part = msg[entryTagStart+16]
+	et.Want = msg[entryTagStart+17]
+	et.Created = time.Unix(0, int64(binary.BigEndian.Uint64(msg[entryTagStart+18:])))
+	return et, part, part != 0 && part&et.Want == part
+}
+
+type entryKey struct {
+	origin, seq uint64
+}
+
+type entryState struct {
+	first   time.Time
+	created time.Time
+	want    byte
+	have    byte
+}
+
+// EntryStats shows how many entries of other nodes arrived in full
+type EntryStats struct {
+	EPS        uint64
+	Completed  uint64
+	Incomplete uint64
+	Pending    int
+	OutOfOrder uint64
+	AckFirst   uint64
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+// CompletionRate is the share of entries that arrived in full within entryTimeout
+func (es EntryStats) CompletionRate() float64 {
+	if es.Completed+es.Incomplete == 0 {
+		return 0
+	}
+	return float64(es.Completed) / float64(es.Completed+es.Incomplete)
+}
+
+// Entries links the commits, reveals, and ACKs of the entries created by
+// other nodes. An entry is completed once all of its parts arrived. The
+// latency is measured from its creation, so it relies on the clock sync.
+type Entries struct {
+	origin uint64
+	seq    uint64
+
+	mtx     sync.Mutex
+	pending map[entryKey]*entryState
+
+	stats         EntryStats
+	totalLatency  time.Duration
+	lastCompleted uint64
+}
+
+// NewEntries creates the entry tracker of the node with the given origin id
+func NewEntries(origin uint64) *Entries {
+	e := new(Entries)
+	e.origin = origin
+	e.pending = make(map[entryKey]*entryState)
+	return e
+}
+
+// Tag creates the tag for a new entry created by this node
+func (e *Entries) Tag(want byte, created time.Time) EntryTag {
+	return EntryTag{Origin: e.origin, Seq: atomic.AddUint64(&e.seq, 1), Want: want, Created: created}
+}
+
+// Receive records the arrival of a part. now is the time on the master's clock.
+func (e *Entries) Receive(msg []byte, now time.Time) {
+	et, part, ok := decodeEntryTag(msg)
+	if !ok || et.Origin == e.origin {
+		return
+	}
+
+	key := entryKey{et.Origin, et.Seq}
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	es, ok := e.pending[key]
+	if !ok {
+		es = &entryState{first: time.Now(), created: et.Created, want: et.Want}
+		e.pending[key] = es
+	}
+
+	switch part {
+	case partReveal:
+		if es.have&partCommit == 0 {
+			e.stats.OutOfOrder++
+		}
+	case partCommitAck:
+		if es.have&partCommit == 0 {
+			e.stats.AckFirst++
+		}
+	case partRevealAck:
+		if es.have&partReveal == 0 {
+			e.stats.AckFirst++
+		}
+	}
+
+	es.have |= part
+	if es.have == es.want {
+		delete(e.pending, key)
+		latency := now.Sub(es.created)
+		e.stats.Completed++
+		e.totalLatency += latency
+		if latency > e.stats.MaxLatency {
+			e.stats.MaxLatency = latency
+		}
+	}
+}
+
+// Expire gives up on entries that didn't complete within entryTimeout and
+// calculates the completed entries per second. Called once a second.
+func (e *Entries) Expire() {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	cutoff := time.Now().Add(-entryTimeout)
+	for key, es := range e.pending {
+		if es.first.Before(cutoff) {
+			delete(e.pending, key)
+			e.stats.Incomplete++
+		}
+	}
+	e.stats.EPS = e.stats.Completed - e.lastCompleted
+	e.lastCompleted = e.stats.Completed
+}
+
+func (e *Entries) Stats() EntryStats {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	es := e.stats
+	es.Pending = len(e.pending)
+	if es.Completed > 0 {
+		es.AvgLatency = e.totalLatency / time.Duration(es.Completed)
+	}
+	return es
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntries_Receive(t *testing.T) {
+	gen := NewGenerator(entryPercent)
+	sender, receiver := NewEntries(1), NewEntries(2)
+
+	created := time.Now()
+	entry := sender.Tag(entryParts, created)
+	tx := sender.Tag(transactionParts, created)
+
+	part := func(typ byte, tag EntryTag, p byte) []byte {
+		msg := gen.CreateMessage(typ)
+		tag.encode(msg, p)
+		return msg
+	}
+
+	now := created.Add(time.Second)
+	receiver.Receive(part(RevealEntry, entry, partReveal), now)
+	receiver.Receive(part(ACK, entry, partRevealAck), now)
+	receiver.Receive(part(CommitEntry, entry, partCommit), now)
+	receiver.Receive(part(ACK, tx, partCommitAck), now)
+
+	es := receiver.Stats()
+	if es.Completed != 0 || es.Pending != 2 {
+		t.Fatalf("unexpected stats before completion %+v", es)
+	}
+	if es.OutOfOrder != 1 || es.AckFirst != 1 {
+		t.Errorf("out of order = %d, ack first = %d, want 1 and 1", es.OutOfOrder, es.AckFirst)
+	}
+
+	receiver.Receive(part(ACK, entry, partCommitAck), now)
+	receiver.Receive(part(Transaction, tx, partCommit), now)
+
+	es = receiver.Stats()
+	if es.Completed != 2 || es.Pending != 0 || es.AvgLatency != time.Second {
+		t.Errorf("unexpected stats after completion %+v", es)
+	}
+
+	// own entries are not tracked
+	sender.Receive(part(Transaction, tx, partCommit), now)
+	if es := sender.Stats(); es.Pending != 0 {
+		t.Errorf("sender tracks its own entry")
+	}
+}
@@ -1,13 +1,22 @@
 package app
 
 import (
+	"encoding/binary"
 	"math/rand"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 type Generator struct {
+	state uint64 // atomic, first for 64-bit alignment
+
 	entry      []weight
 	entryRange float64
+
+	pooled int32 // atomic bool
+	mtx    sync.Mutex
+	slab   []byte
 }
 
 type weight struct {
@@ -30,16 +39,83 @@ func NewGenerator(prct map[byte]float64) *Generator {
 		return g.entry[i].slot < g.entry[j].slot
 	})
 
+	g.state = rand.Uint64()
+
 	return g
 }
 
+// SetPooled switches between allocating every message separately and the
+// lower allocation path that carves messages out of a shared slab
+func (g *Generator) SetPooled(pooled bool) {
+	var v int32
+	if pooled {
+		v = 1
+	}
+	atomic.StoreInt32(&g.pooled, v)
+}
+
 func (g *Generator) CreateMessage(typ byte) []byte {
+	if atomic.LoadInt32(&g.pooled) == 1 {
+		return g.createPooled(typ)
+	}
+
 	buf := make([]byte, avgSize[typ])
 	rand.Read(buf)
 	buf[0] = typ
 	return buf
 }
 
+// createPooled takes the message buffer from a large preallocated slab, so
+// only one allocation is made for many messages. The slab is never reused, it
+// is released by the gc once all messages carved from it are gone, so the
+// network is free to hold on to the message.
+// The random content comes from a xorshift generator, which doesn't have the
+// lock contention of the global math/rand source. Every message gets its own
+// seed from a shared counter, so only carving the slab needs the lock.
+func (g *Generator) createPooled(typ byte) []byte {
+	size := avgSize[typ]
+
+	g.mtx.Lock()
+	if len(g.slab) < size {
+		g.slab = make([]byte, slabSize)
+	}
+	buf := g.slab[:size:size]
+	g.slab = g.slab[size:]
+	g.mtx.Unlock()
+
+	x := splitmix64(atomic.AddUint64(&g.state, 0x9E3779B97F4A7C15)) | 1
+	for i := 0; i < size; i += 8 {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		if i+8 <= size {
+			binary.LittleEndian.PutUint64(buf[i:], x)
+		} else {
+			for j := i; j < size; j++ {
+				buf[j] = byte(x >> (8 * (j - i)))
+			}
+		}
+	}
+
+	buf[0] = typ
+	return buf
+}
+
+// splitmix64 scrambles the counter into a well distributed seed
+func splitmix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+// CreateAuthorityMessage creates an EOM, DBSig, or Heartbeat from the
+// authority server with the given identity
+func (g *Generator) CreateAuthorityMessage(typ byte, height, minute, identity int) []byte {
+	buf := g.CreateMessage(typ)
+	encodeAuthority(buf, height, minute, identity)
+	return buf
+}
+
 func (g *Generator) WeightedRandomType() byte {
 	r := rand.Float64() * g.entryRange
 	for _, w := range g.entry {
@@ -42,3 +42,16 @@ func TestGenerator_WeightedRandomType(t *testing.T) {
 		})
 	}
 }
+
+func benchmarkCreateMessage(b *testing.B, pooled bool) {
+	gen := NewGenerator(entryPercent)
+	gen.SetPooled(pooled)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gen.CreateMessage(RevealEntry)
+	}
+}
+
+func BenchmarkGenerator_CreateMessage(b *testing.B)       { benchmarkCreateMessage(b, false) }
+func BenchmarkGenerator_CreateMessagePooled(b *testing.B) { benchmarkCreateMessage(b, true) }
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Relayed messages end in a trailer that changes on every hop: the path of
+// the nodes that relayed it, a flag if the path is recorded, and the number
+// of hops. The trailer is not part of the message's hash.
+const (
+	pathSlots     = 16
+	hopTrailerLen = pathSlots*2 + 2
+)
+
+// hop counts beyond hopBuckets share the last bucket
+const hopBuckets = 16
+
+// hasTrailer is true for the message types that are relayed
+func hasTrailer(typ byte) bool {
+	return typ >= ACK && typ <= Transaction
+}
+
+// messageHash is the hash of the message without the hop trailer
+func messageHash(msg []byte) [32]byte {
+	if len(msg) >= hopTrailerLen && hasTrailer(msg[0]) {
+		return sha256.Sum256(msg[:len(msg)-hopTrailerLen])
+	}
+	if len(msg) >= tracerHeaderLen && msg[0] == Tracer {
+		return sha256.Sum256(msg[:tracerFixedLen])
+	}
+	return sha256.Sum256(msg)
+}
+
+// shortID is the id of the node in recorded paths
+func shortID(id uint64) uint16 {
+	return uint16(id)
+}
+
+// stampTrailer prepares the trailer of a message created by this node. A
+// pathSample fraction of messages records the path.
+func stampTrailer(msg []byte, id uint64) {
+	if len(msg) < hopTrailerLen || !hasTrailer(msg[0]) {
+		return
+	}
+	trailer := msg[len(msg)-hopTrailerLen:]
+	for i := range trailer {
+		trailer[i] = 0
+	}
+	binary.BigEndian.PutUint16(trailer, shortID(id))
+	if rand.Float64() < pathSample {
+		trailer[hopTrailerLen-2] = 1
+	}
+	trailer[hopTrailerLen-1] = 1
+}
+
+// decodeTrailer returns the hops so far and the recorded path, if any
+func decodeTrailer(msg []byte) (hops int, path []uint16, ok bool) {
+	if len(msg) < hopTrailerLen || !hasTrailer(msg[0]) {
+		return 0, nil, false
+	}
+	trailer := msg[len(msg)-hopTrailerLen:]
+	hops = int(trailer[hopTrailerLen-1])
+	if trailer[hopTrailerLen-2] == 1 {
+		n := hops
+		if n > pathSlots {
+			n = pathSlots
+		}
+		for i := 0; i < n; i++ {
+			path = append(path, binary.BigEndian.Uint16(trailer[i*2:]))
+		}
+	}
+	return hops, path, true
+}
+
+// nextHop returns a copy of the message with this node added to the trailer,
+// ready to be relayed. The original may still be in use by the network.
+func nextHop(msg []byte, id uint64) []byte {
+	if len(msg) < hopTrailerLen || !hasTrailer(msg[0]) {
+		return msg
+	}
+	next := make([]byte, len(msg))
+	copy(next, msg)
+	trailer := next[len(next)-hopTrailerLen:]
+	hops := trailer[hopTrailerLen-1]
+	if trailer[hopTrailerLen-2] == 1 && int(hops) < pathSlots {
+		binary.BigEndian.PutUint16(trailer[int(hops)*2:], shortID(id))
+	}
+	if hops < 255 {
+		trailer[hopTrailerLen-1] = hops + 1
+	}
+	return next
+}
+
+// HopDist is the distribution of hops the messages of a type needed to reach
+// this node. The last bucket holds everything beyond.
+type HopDist struct {
+	Name   string
+	Counts []uint64
+	Total  uint64
+	Avg    float64
+	Max    int
+}
+
+// Histogram lists the share of messages for each amount of hops
+func (hd HopDist) Histogram() string {
+	var parts []string
+	for hops, c := range hd.Counts {
+		if c == 0 {
+			continue
+		}
+		label := fmt.Sprint(hops)
+		if hops == len(hd.Counts)-1 {
+			label += "+"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %.1f%%", label, float64(c)/float64(hd.Total)*100))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PathSample is the recorded path of a message, starting at its creator and
+// ending at this node
+type PathSample struct {
+	Time  time.Time
+	Type  string
+	Hops  int
+	Nodes []string
+}
+
+func (ps PathSample) String() string {
+	return strings.Join(ps.Nodes, " → ")
+}
+
+type rawPath struct {
+	time time.Time
+	typ  byte
+	hops int
+	path []uint16
+}
+
+// Hops collects the hop counts and the recorded paths of incoming messages
+type Hops struct {
+	counts [MESSAGEMAX][hopBuckets]uint64
+	max    [MESSAGEMAX]int64
+
+	mtx   sync.Mutex
+	paths []rawPath
+	next  int
+}
+
+func NewHops() *Hops {
+	h := new(Hops)
+	h.paths = make([]rawPath, 0, pathHistory)
+	return h
+}
+
+// Receive records the first arrival of a message
+func (h *Hops) Receive(msg []byte) {
+	hops, path, ok := decodeTrailer(msg)
+	if !ok {
+		return
+	}
+	typ := msg[0]
+	bucket := hops
+	if bucket >= hopBuckets {
+		bucket = hopBuckets - 1
+	}
+	atomic.AddUint64(&h.counts[typ][bucket], 1)
+	for {
+		max := atomic.LoadInt64(&h.max[typ])
+		if int64(hops) <= max || atomic.CompareAndSwapInt64(&h.max[typ], max, int64(hops)) {
+			break
+		}
+	}
+
+	if path != nil {
+		rp := rawPath{time: time.Now(), typ: typ, hops: hops, path: path}
+		h.mtx.Lock()
+		if len(h.paths) < pathHistory {
+			h.paths = append(h.paths, rp)
+		} else {
+			h.paths[h.next] = rp
+		}
+		h.next = (h.next + 1) % pathHistory
+		h.mtx.Unlock()
+	}
+}
+
+// Dists returns the hop distribution of every type that was received
+func (h *Hops) Dists() []HopDist {
+	var dists []HopDist
+	for typ := byte(0); typ < MESSAGEMAX; typ++ {
+		hd := HopDist{Name: MessageName(int(typ)), Counts: make([]uint64, hopBuckets)}
+		sum := uint64(0)
+		for hops := range hd.Counts {
+			c := atomic.LoadUint64(&h.counts[typ][hops])
+			hd.Counts[hops] = c
+			hd.Total += c
+			sum += c * uint64(hops)
+		}
+		if hd.Total == 0 {
+			continue
+		}
+		hd.Avg = float64(sum) / float64(hd.Total)
+		hd.Max = int(atomic.LoadInt64(&h.max[typ]))
+		dists = append(dists, hd)
+	}
+	return dists
+}
+
+// Paths returns the recorded paths, newest first. Node ids are replaced by
+// the names in names, the last node is self.
+func (h *Hops) Paths(names map[uint16]string, self string) []PathSample {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	samples := make([]PathSample, 0, len(h.paths))
+	for i := 1; i <= len(h.paths); i++ {
+		rp := h.paths[(h.next-i+len(h.paths))%len(h.paths)]
+		ps := PathSample{Time: rp.time, Type: MessageName(int(rp.typ)), Hops: rp.hops}
+		for _, id := range rp.path {
+			name, ok := names[id]
+			if !ok {
+				name = fmt.Sprintf("%04x", id)
+			}
+			ps.Nodes = append(ps.Nodes, name)
+		}
+		if rp.hops > pathSlots {
+			ps.Nodes = append(ps.Nodes, "…")
+		}
+		ps.Nodes = append(ps.Nodes, self)
+		samples = append(samples, ps)
+	}
+	return samples
+}
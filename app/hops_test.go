@@ -0,0 +1,41 @@
+package app
+
+import "testing"
+
+func TestHops_Trailer(t *testing.T) {
+	old := pathSample
+	pathSample = 1
+	defer func() { pathSample = old }()
+
+	gen := NewGenerator(entryPercent)
+	msg := gen.CreateMessage(RevealEntry)
+	stampTrailer(msg, 0x1001)
+	hash := messageHash(msg)
+
+	relayed := nextHop(nextHop(msg, 0x2002), 0x3003)
+	if messageHash(relayed) != hash {
+		t.Error("relaying changed the hash")
+	}
+	if hops, _, _ := decodeTrailer(msg); hops != 1 {
+		t.Errorf("relaying changed the original message, hops = %d", hops)
+	}
+
+	hops, path, ok := decodeTrailer(relayed)
+	if !ok || hops != 3 {
+		t.Fatalf("hops = %d, want 3", hops)
+	}
+	if len(path) != 3 || path[0] != 0x1001 || path[1] != 0x2002 || path[2] != 0x3003 {
+		t.Errorf("unexpected path %x", path)
+	}
+
+	h := NewHops()
+	h.Receive(relayed)
+	paths := h.Paths(map[uint16]string{0x1001: "a", 0x2002: "b"}, "self")
+	if len(paths) != 1 || paths[0].String() != "a → b → 3003 → self" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+	dists := h.Dists()
+	if len(dists) != 1 || dists[0].Name != "RevealEntry" || dists[0].Max != 3 {
+		t.Errorf("unexpected distribution %+v", dists)
+	}
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"container/list"
+	"sync"
+	"unsafe"
+)
+
+// LRUReplay remembers a fixed number of hashes, forgetting the least recently
+// seen one when full. Memory is bounded but dupes older than the capacity are
+// missed.
+type LRUReplay struct {
+	mtx      sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[[32]byte]*list.Element
+}
+
+var _ Deduplicator = (*LRUReplay)(nil)
+
+func NewLRUReplay(capacity int) *LRUReplay {
+	l := new(LRUReplay)
+	l.capacity = capacity
+	l.order = list.New()
+	l.entries = make(map[[32]byte]*list.Element, capacity)
+	return l
+}
+
+func (l *LRUReplay) Dupe(hash [32]byte) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if e, ok := l.entries[hash]; ok {
+		l.order.MoveToFront(e)
+		return true
+	}
+
+	if l.order.Len() >= l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.([32]byte))
+	}
+	l.entries[hash] = l.order.PushFront(hash)
+	return false
+}
+
+func (l *LRUReplay) Len() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.order.Len()
+}
+
+// lruEntryBytes is the map entry of a hash, its list element, and the copy of
+// the hash the element's interface points to
+var lruEntryBytes = mapEntryBytes(unsafe.Sizeof([32]byte{}), unsafe.Sizeof(&list.Element{})) +
+	uint64(unsafe.Sizeof(list.Element{})+unsafe.Sizeof([32]byte{}))
+
+// Memory estimates the bytes used by the map and the list
+func (l *LRUReplay) Memory() uint64 {
+	return uint64(l.Len()) * lruEntryBytes
+}
+
+// Stop does nothing, the LRU has no background rotation
+func (l *LRUReplay) Stop() {}
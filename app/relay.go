@@ -0,0 +1,242 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+// RelayPolicy decides how a new simulated factom message is passed on and
+// whether requests of other nodes are answered
+type RelayPolicy interface {
+	// Targets returns where to send the message to. No targets means the
+	// message is not relayed.
+	Targets(n network.Network, typ byte) []string
+	// Reply decides if a request (MissingMsg, DBStateRequest) is answered.
+	// The answer always goes back to the peer that asked.
+	Reply(typ byte) bool
+}
+
+var validRelays = []string{"flood", "probabilistic", "fanout", "authority", "adaptive"}
+
+// RelayNames returns the available relay policies
+func RelayNames() []string {
+	return validRelays
+}
+
+// NewRelayPolicy creates the relay policy with the given name
+//
+//	flood: broadcast everything and answer every request, the original behavior
+//	probabilistic: params is the probability of relaying a message or
+//	        answering a request
+//	fanout: params are lines of Type=peers, "*" sets the default.
+//	        a negative amount sends to all peers, zero doesn't relay.
+//	        requests are answered unless their amount is zero
+//	authority: full broadcast for EOM and DBSig, broadcast the rest,
+//	        answer every request
+//	adaptive: params are "start,min,max" of the fanout that is adjusted at
+//	        runtime, answer every request
+func NewRelayPolicy(name, params string) (RelayPolicy, error) {
+	switch name {
+	case "", "flood":
+		return FloodRelay{}, nil
+	case "probabilistic":
+		p, err := strconv.ParseFloat(strings.TrimSpace(params), 64)
+		if err != nil {
+			return nil, err
+		}
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("probability has to be between 0 and 1")
+		}
+		return ProbabilisticRelay{P: p}, nil
+	case "fanout":
+		return parseFanoutRelay(params)
+	case "authority":
+		return AuthorityRelay{}, nil
+	case "adaptive":
+		return parseAdaptiveRelay(params)
+	}
+	return nil, fmt.Errorf("unknown relay policy \"%s\"", name)
+}
+
+// FloodRelay broadcasts every message to the library's fanout
+type FloodRelay struct{}
+
+func (FloodRelay) Targets(n network.Network, typ byte) []string {
+	return []string{n.BroadcastFlag()}
+}
+
+func (FloodRelay) Reply(typ byte) bool { return true }
+
+// ProbabilisticRelay only broadcasts a message with probability P
+type ProbabilisticRelay struct{ P float64 }
+
+func (pr ProbabilisticRelay) Targets(n network.Network, typ byte) []string {
+	if rand.Float64() < pr.P {
+		return []string{n.BroadcastFlag()}
+	}
+	return nil
+}
+
+func (pr ProbabilisticRelay) Reply(typ byte) bool {
+	return rand.Float64() < pr.P
+}
+
+// FanoutRelay sends each message type to a set amount of distinct random
+// peers. Until the library reports its peers, random peers are picked
+// independently, so the same peer may be picked twice.
+type FanoutRelay struct {
+	Fanout [MESSAGEMAX]int
+}
+
+func parseFanoutRelay(params string) (RelayPolicy, error) {
+	fr := new(FanoutRelay)
+	def := 0
+	hasDefault := false
+	var set [MESSAGEMAX]bool
+	for _, line := range strings.Split(params, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid line \"%s\", expected Type=peers", line)
+		}
+		peers, err := strconv.Atoi(strings.TrimSpace(split[1]))
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSpace(split[0])
+		if name == "*" {
+			def = peers
+			hasDefault = true
+			continue
+		}
+		typ, ok := MessageType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown message type \"%s\"", name)
+		}
+		fr.Fanout[typ] = peers
+		set[typ] = true
+	}
+	if !hasDefault {
+		return nil, fmt.Errorf("fanout needs a default line \"*=peers\"")
+	}
+	for typ := range fr.Fanout {
+		if !set[typ] {
+			fr.Fanout[typ] = def
+		}
+	}
+	return fr, nil
+}
+
+func (fr *FanoutRelay) Targets(n network.Network, typ byte) []string {
+	peers := fr.Fanout[typ]
+	if peers < 0 {
+		return []string{n.FullBroadcastFlag()}
+	}
+	if connected := n.Peers(); len(connected) > 0 {
+		return pickPeers(connected, peers)
+	}
+	targets := make([]string, peers)
+	for i := range targets {
+		targets[i] = n.RandomFlag()
+	}
+	return targets
+}
+
+func (fr *FanoutRelay) Reply(typ byte) bool {
+	return fr.Fanout[typ] != 0
+}
+
+// pickPeers returns k distinct peers at random, or all of them if there are
+// fewer than k
+func pickPeers(peers []string, k int) []string {
+	picked := append([]string(nil), peers...)
+	if k >= len(picked) {
+		return picked
+	}
+	for i := 0; i < k; i++ {
+		j := i + rand.Intn(len(picked)-i)
+		picked[i], picked[j] = picked[j], picked[i]
+	}
+	return picked[:k]
+}
+
+// AuthorityRelay sends EOMs and DBSigs to every peer and broadcasts the rest
+type AuthorityRelay struct{}
+
+func (AuthorityRelay) Targets(n network.Network, typ byte) []string {
+	if typ == EOM || typ == DBSig {
+		return []string{n.FullBroadcastFlag()}
+	}
+	return []string{n.BroadcastFlag()}
+}
+
+func (AuthorityRelay) Reply(typ byte) bool { return true }
+
+// RelayConfig holds the relay policy currently in use
+type RelayConfig struct {
+	mtx    sync.RWMutex
+	name   string
+	params string
+	policy RelayPolicy
+}
+
+func NewRelayConfig() *RelayConfig {
+	rc := new(RelayConfig)
+	rc.name = "flood"
+	rc.policy = FloodRelay{}
+	return rc
+}
+
+func (rc *RelayConfig) Set(name, params string) error {
+	policy, err := NewRelayPolicy(name, params)
+	if err != nil {
+		return err
+	}
+	rc.mtx.Lock()
+	rc.name = name
+	rc.params = params
+	rc.policy = policy
+	rc.mtx.Unlock()
+	return nil
+}
+
+// Policy returns the name and the params of the current policy
+func (rc *RelayConfig) Policy() (string, string) {
+	rc.mtx.RLock()
+	defer rc.mtx.RUnlock()
+	return rc.name, rc.params
+}
+
+func (rc *RelayConfig) current() RelayPolicy {
+	rc.mtx.RLock()
+	defer rc.mtx.RUnlock()
+	return rc.policy
+}
+
+// FanoutStats returns the state of the adaptive fanout, if in use
+func (rc *RelayConfig) FanoutStats() FanoutStats {
+	if ar, ok := rc.current().(*AdaptiveRelay); ok {
+		return ar.Stats()
+	}
+	return FanoutStats{}
+}
+
+func (rc *RelayConfig) Targets(n network.Network, typ byte) []string {
+	rc.mtx.RLock()
+	policy := rc.policy
+	rc.mtx.RUnlock()
+	return policy.Targets(n, typ)
+}
+
+// Reply decides if a request is answered according to the current policy
+func (rc *RelayConfig) Reply(typ byte) bool {
+	return rc.current().Reply(typ)
+}
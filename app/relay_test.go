@@ -0,0 +1,123 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+func Test_pickPeers(t *testing.T) {
+	peers := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 100; i++ {
+		picked := pickPeers(peers, 3)
+		if len(picked) != 3 {
+			t.Fatalf("picked %d peers, want 3", len(picked))
+		}
+		seen := make(map[string]bool)
+		for _, p := range picked {
+			if seen[p] {
+				t.Fatalf("peer %s picked twice: %v", p, picked)
+			}
+			seen[p] = true
+		}
+	}
+	if picked := pickPeers(peers, 8); len(picked) != len(peers) {
+		t.Errorf("picked %v, want all peers", picked)
+	}
+	if peers[0] != "a" || peers[4] != "e" {
+		t.Errorf("pickPeers changed the original %v", peers)
+	}
+}
+
+func Test_parseFanoutRelay(t *testing.T) {
+	tests := []struct {
+		params string
+		want   map[byte]int
+		err    bool
+	}{
+		{"*=4", map[byte]int{ACK: 4, EOM: 4, MissingMsg: 4}, false},
+		{"*=2\nEOM=-1\n\n ACK = 0 ", map[byte]int{ACK: 0, EOM: -1, DBSig: 2}, false},
+		{"MissingMsg=0\n*=3", map[byte]int{MissingMsg: 0, DBStateRequest: 3}, false},
+		{"", nil, true},
+		{"EOM=-1", nil, true},
+		{"*=4\nEOM", nil, true},
+		{"*=four", nil, true},
+		{"*=4\nBogus=1", nil, true},
+	}
+	for _, tt := range tests {
+		policy, err := parseFanoutRelay(tt.params)
+		if (err != nil) != tt.err {
+			t.Errorf("parseFanoutRelay(%q) error = %v, want error %v", tt.params, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		fr := policy.(*FanoutRelay)
+		for typ, want := range tt.want {
+			if fr.Fanout[typ] != want {
+				t.Errorf("parseFanoutRelay(%q) fanout of %d = %d, want %d", tt.params, typ, fr.Fanout[typ], want)
+			}
+		}
+	}
+}
+
+func TestRelayPolicy_Targets(t *testing.T) {
+	n := network.NewLoopback(1)
+	full, bcast, random := n.FullBroadcastFlag(), n.BroadcastFlag(), n.RandomFlag()
+	fanout, err := parseFanoutRelay("*=2\nEOM=-1\nACK=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy RelayPolicy
+		typ    byte
+		want   []string
+	}{
+		{"flood", FloodRelay{}, ACK, []string{bcast}},
+		{"probabilistic never", ProbabilisticRelay{P: 0}, ACK, nil},
+		{"probabilistic always", ProbabilisticRelay{P: 1}, EOM, []string{bcast}},
+		{"authority EOM", AuthorityRelay{}, EOM, []string{full}},
+		{"authority DBSig", AuthorityRelay{}, DBSig, []string{full}},
+		{"authority ACK", AuthorityRelay{}, ACK, []string{bcast}},
+		{"authority Heartbeat", AuthorityRelay{}, Heartbeat, []string{bcast}},
+		{"fanout all", fanout, EOM, []string{full}},
+		{"fanout none", fanout, ACK, []string{}},
+		{"fanout random", fanout, DBSig, []string{random, random}},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Targets(n, tt.typ); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Targets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRelayPolicy_Reply(t *testing.T) {
+	fanout, err := parseFanoutRelay("*=2\nMissingMsg=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		policy RelayPolicy
+		typ    byte
+		want   bool
+	}{
+		{"flood", FloodRelay{}, MissingMsg, true},
+		{"probabilistic never", ProbabilisticRelay{P: 0}, DBStateRequest, false},
+		{"probabilistic always", ProbabilisticRelay{P: 1}, MissingMsg, true},
+		{"authority", AuthorityRelay{}, DBStateRequest, true},
+		{"adaptive", &AdaptiveRelay{fanout: 4, min: 2, max: 8}, MissingMsg, true},
+		{"fanout ignored", fanout, MissingMsg, false},
+		{"fanout default", fanout, DBStateRequest, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Reply(tt.typ); got != tt.want {
+			t.Errorf("%s: Reply() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
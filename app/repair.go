@@ -0,0 +1,241 @@
+package app
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// ACKs created by the load generator are chained per node: after the type
+// they carry the origin id, the sequence number, and the hash of the
+// origin's previous ACK. A node that receives an ACK without having seen the
+// previous one has found a gap.
+const ackHeaderLen = 49
+
+func encodeAck(msg []byte, origin, seq uint64, prev [32]byte) {
+	binary.BigEndian.PutUint64(msg[1:], origin)
+	binary.BigEndian.PutUint64(msg[9:], seq)
+	copy(msg[17:ackHeaderLen], prev[:])
+}
+
+func decodeAck(msg []byte) (origin, seq uint64, prev [32]byte, ok bool) {
+	if len(msg) < ackHeaderLen {
+		return 0, 0, prev, false
+	}
+	copy(prev[:], msg[17:ackHeaderLen])
+	return binary.BigEndian.Uint64(msg[1:]), binary.BigEndian.Uint64(msg[9:]), prev, true
+}
+
+// MissingMsg carries the hash of the requested message after the type, the
+// rest is random so requests for the same message aren't duplicates.
+// MissingReply carries the requested message after the type.
+const missingHeaderLen = 33
+
+func encodeMissing(msg []byte, hash [32]byte) {
+	copy(msg[1:missingHeaderLen], hash[:])
+}
+
+func decodeMissing(msg []byte) (hash [32]byte, ok bool) {
+	if len(msg) < missingHeaderLen {
+		return hash, false
+	}
+	copy(hash[:], msg[1:missingHeaderLen])
+	return hash, true
+}
+
+// RepairStats shows how well this node recovered from gaps in the ACKs
+type RepairStats struct {
+	Requested  uint64
+	Served     uint64
+	Unknown    uint64
+	Repaired   uint64
+	Natural    uint64
+	Failed     uint64
+	Pending    int
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+// SuccessRate is the share of gaps that were closed by a MissingReply
+func (rs RepairStats) SuccessRate() float64 {
+	if rs.Repaired+rs.Failed == 0 {
+		return 0
+	}
+	return float64(rs.Repaired) / float64(rs.Repaired+rs.Failed)
+}
+
+type gap struct {
+	detected  time.Time
+	requested time.Time
+	attempts  int
+}
+
+type ackOrigin struct {
+	first uint64
+}
+
+// Repair keeps the recent ACKs to answer MissingMsg requests with and keeps
+// track of the gaps in the ACKs of other nodes
+type Repair struct {
+	origin uint64
+
+	seqMtx sync.Mutex
+	seq    uint64
+	prev   [32]byte
+
+	mtx     sync.Mutex
+	store   map[[32]byte][]byte
+	ring    [][32]byte
+	next    int
+	origins map[uint64]*ackOrigin
+	gaps    map[[32]byte]*gap
+
+	stats        RepairStats
+	totalLatency time.Duration
+}
+
+// NewRepair creates the repair state of the node with the given origin id
+func NewRepair(origin uint64) *Repair {
+	r := new(Repair)
+	r.origin = origin
+	r.store = make(map[[32]byte][]byte)
+	r.ring = make([][32]byte, repairStore)
+	r.origins = make(map[uint64]*ackOrigin)
+	r.gaps = make(map[[32]byte]*gap)
+	return r
+}
+
+// ChainAck turns the ACK into this node's next ACK in the chain. The rest of
+// the message can't change afterward.
+func (r *Repair) ChainAck(msg []byte) []byte {
+	r.seqMtx.Lock()
+	r.seq++
+	encodeAck(msg, r.origin, r.seq, r.prev)
+	hash := messageHash(msg)
+	r.prev = hash
+	r.seqMtx.Unlock()
+
+	r.mtx.Lock()
+	r.keep(hash, msg)
+	r.mtx.Unlock()
+	return msg
+}
+
+// keep stores the message, evicting the oldest one once the store is full.
+// Has to be called with the mutex held.
+func (r *Repair) keep(hash [32]byte, msg []byte) {
+	if _, ok := r.store[hash]; ok {
+		return
+	}
+	delete(r.store, r.ring[r.next])
+	r.ring[r.next] = hash
+	r.next = (r.next + 1) % len(r.ring)
+	r.store[hash] = msg
+}
+
+// Ack records an ACK that arrived, either from the network or in a
+// MissingReply, and looks for a gap before it
+func (r *Repair) Ack(msg []byte, hash [32]byte, repaired bool) {
+	origin, seq, prev, ok := decodeAck(msg)
+	if !ok {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.keep(hash, msg)
+
+	if g, ok := r.gaps[hash]; ok {
+		delete(r.gaps, hash)
+		if !g.requested.IsZero() {
+			if repaired {
+				latency := time.Since(g.detected)
+				r.stats.Repaired++
+				r.totalLatency += latency
+				if latency > r.stats.MaxLatency {
+					r.stats.MaxLatency = latency
+				}
+			} else {
+				r.stats.Natural++
+			}
+		}
+	}
+
+	if origin == r.origin {
+		return
+	}
+	o, ok := r.origins[origin]
+	if !ok {
+		// the gaps from before this node joined aren't interesting
+		r.origins[origin] = &ackOrigin{first: seq}
+		return
+	}
+	if seq < o.first {
+		o.first = seq
+		return
+	}
+	if seq == o.first {
+		return
+	}
+	if _, seen := r.store[prev]; seen {
+		return
+	}
+	if _, known := r.gaps[prev]; !known {
+		r.gaps[prev] = &gap{detected: time.Now()}
+	}
+}
+
+// Due returns the hashes of the gaps that need a MissingMsg now. Gaps get
+// repairDelay to be filled by the network on their own, a request that isn't
+// answered within repairTimeout is sent again. After repairAttempts requests
+// the gap counts as failed.
+func (r *Repair) Due() [][32]byte {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+	var due [][32]byte
+	for hash, g := range r.gaps {
+		switch {
+		case g.requested.IsZero():
+			if now.Sub(g.detected) < repairDelay {
+				continue
+			}
+		case now.Sub(g.requested) < repairTimeout:
+			continue
+		case g.attempts >= repairAttempts:
+			delete(r.gaps, hash)
+			r.stats.Failed++
+			continue
+		}
+		g.requested = now
+		g.attempts++
+		r.stats.Requested++
+		due = append(due, hash)
+	}
+	return due
+}
+
+// Answer returns the requested message if this node has it
+func (r *Repair) Answer(hash [32]byte) ([]byte, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	msg, ok := r.store[hash]
+	if ok {
+		r.stats.Served++
+	} else {
+		r.stats.Unknown++
+	}
+	return msg, ok
+}
+
+func (r *Repair) Stats() RepairStats {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	rs := r.stats
+	rs.Pending = len(r.gaps)
+	if rs.Repaired > 0 {
+		rs.AvgLatency = r.totalLatency / time.Duration(rs.Repaired)
+	}
+	return rs
+}
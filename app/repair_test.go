@@ -0,0 +1,63 @@
+package app
+
+import "testing"
+
+func TestRepair_Gap(t *testing.T) {
+	old := repairDelay
+	repairDelay = 0
+	defer func() { repairDelay = old }()
+
+	gen := NewGenerator(entryPercent)
+	sender, receiver := NewRepair(1), NewRepair(2)
+
+	acks := make([][]byte, 4)
+	for i := range acks {
+		acks[i] = sender.ChainAck(gen.CreateMessage(ACK))
+	}
+
+	// the second ack is lost
+	for _, i := range []int{0, 2, 3} {
+		receiver.Ack(acks[i], messageHash(acks[i]), false)
+	}
+	if rs := receiver.Stats(); rs.Pending != 1 {
+		t.Fatalf("pending = %d, want 1", rs.Pending)
+	}
+
+	due := receiver.Due()
+	if len(due) != 1 || due[0] != messageHash(acks[1]) {
+		t.Fatalf("due = %x, want the lost ack", due)
+	}
+
+	msg, ok := sender.Answer(due[0])
+	if !ok {
+		t.Fatal("sender doesn't have its own ack")
+	}
+	receiver.Ack(msg, messageHash(msg), true)
+
+	rs := receiver.Stats()
+	if rs.Pending != 0 || rs.Repaired != 1 || rs.SuccessRate() != 1 {
+		t.Errorf("unexpected stats after repair %+v", rs)
+	}
+	if _, ok := receiver.Answer(messageHash(acks[1])); !ok {
+		t.Error("receiver can't answer for the repaired ack")
+	}
+}
+
+func TestRepair_Store(t *testing.T) {
+	old := repairStore
+	repairStore = 2
+	defer func() { repairStore = old }()
+
+	gen := NewGenerator(entryPercent)
+	r := NewRepair(1)
+	first := r.ChainAck(gen.CreateMessage(ACK))
+	r.ChainAck(gen.CreateMessage(ACK))
+	r.ChainAck(gen.CreateMessage(ACK))
+
+	if _, ok := r.Answer(messageHash(first)); ok {
+		t.Error("oldest ack was not evicted")
+	}
+	if len(r.store) != 2 {
+		t.Errorf("store has %d acks, want 2", len(r.store))
+	}
+}
@@ -1,30 +1,44 @@
 package app
 
 import (
+	"fmt"
 	"sync"
 	"time"
+	"unsafe"
 )
 
 type bucket map[string]bool
+
+// Replay stores the hex encoded hashes in a rotating list of maps
 type Replay struct {
 	interval time.Duration
 	size     uint
+	stop     chan struct{}
 	mtx      sync.RWMutex
 	buckets  []bucket
 }
 
+var _ Deduplicator = (*Replay)(nil)
+
 func NewReplay(interval time.Duration, size uint) *Replay {
 	r := new(Replay)
 	r.interval = interval
 	r.size = size
 	r.buckets = []bucket{make(bucket)}
+	r.stop = make(chan struct{})
 	go r.start()
 	return r
 }
 
 func (r *Replay) start() {
 	ticker := time.NewTicker(r.interval)
-	for range ticker.C {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
 		r.mtx.Lock()
 		r.buckets = append([]bucket{make(bucket)}, r.buckets...)
 		if len(r.buckets) > int(r.size) {
@@ -34,15 +48,37 @@ func (r *Replay) start() {
 	}
 }
 
-func (r *Replay) Dupe(hash string) bool {
+func (r *Replay) Dupe(hash [32]byte) bool {
+	key := fmt.Sprintf("%x", hash)
+
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 
 	for _, b := range r.buckets {
-		if b[hash] {
+		if b[key] {
 			return true
 		}
 	}
-	r.buckets[0][hash] = true
+	r.buckets[0][key] = true
 	return false
 }
+
+func (r *Replay) Len() int {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	n := 0
+	for _, b := range r.buckets {
+		n += len(b)
+	}
+	return n
+}
+
+// replayEntryBytes is the map entry of a hex key plus its 64 bytes of text
+var replayEntryBytes = mapEntryBytes(unsafe.Sizeof(""), unsafe.Sizeof(true)) + 64
+
+// Memory estimates the bytes used by the stored keys
+func (r *Replay) Memory() uint64 {
+	return uint64(r.Len()) * replayEntryBytes
+}
+
+func (r *Replay) Stop() { close(r.stop) }
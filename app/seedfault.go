@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var validSeedFaults = []string{"none", "slow", "error", "empty", "malformed", "unreachable", "flapping"}
+
+// SeedFaultNames returns the available misbehaviours of the seed server
+func SeedFaultNames() []string {
+	return validSeedFaults
+}
+
+// SeedFault is a misbehaviour of the seed server, to test how the libraries
+// bootstrap when the seed server isn't reliable. Stale addresses of nodes
+// that left can be tested with a registration expiry of zero.
+type SeedFault struct {
+	Mode   string
+	Params string
+
+	delay    time.Duration
+	status   int
+	count    int
+	up, down time.Duration
+	start    time.Time
+}
+
+// NewSeedFault creates the misbehaviour with the given name
+//
+//	none: behave
+//	slow: params is how long to wait before answering, default 10s
+//	error: params is the http status to answer with, default 500
+//	empty: answer with an empty list
+//	malformed: mix lines that aren't addresses into the list
+//	unreachable: params is how many addresses that never answer are put in
+//	             front of the list, default 8
+//	flapping: params is "up,down", the seed server drops connections for down
+//	          after being available for up, default 30s,30s
+func NewSeedFault(mode, params string) (*SeedFault, error) {
+	sf := &SeedFault{Mode: mode, Params: params, start: time.Now()}
+	params = strings.TrimSpace(params)
+	var err error
+	switch mode {
+	case "", "none":
+		sf.Mode = "none"
+	case "slow":
+		sf.delay = time.Second * 10
+		if params != "" {
+			sf.delay, err = time.ParseDuration(params)
+		}
+	case "error":
+		sf.status = 500
+		if params != "" {
+			sf.status, err = strconv.Atoi(params)
+		}
+		if err == nil && (sf.status < 400 || sf.status > 599) {
+			err = fmt.Errorf("the status has to be between 400 and 599")
+		}
+	case "empty", "malformed":
+	case "unreachable":
+		sf.count = 8
+		if params != "" {
+			sf.count, err = strconv.Atoi(params)
+		}
+		if err == nil && (sf.count < 1 || sf.count > 254) {
+			err = fmt.Errorf("the count has to be between 1 and 254")
+		}
+	case "flapping":
+		sf.up, sf.down = time.Second*30, time.Second*30
+		if params != "" {
+			split := strings.Split(params, ",")
+			if len(split) != 2 {
+				return nil, fmt.Errorf("flapping expects \"up,down\"")
+			}
+			if sf.up, err = time.ParseDuration(strings.TrimSpace(split[0])); err == nil {
+				sf.down, err = time.ParseDuration(strings.TrimSpace(split[1]))
+			}
+		}
+		if err == nil && (sf.up <= 0 || sf.down <= 0) {
+			err = fmt.Errorf("up and down have to be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown seed fault \"%s\"", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// Available is false while a flapping seed server is down
+func (sf *SeedFault) Available(now time.Time) bool {
+	if sf.Mode != "flapping" {
+		return true
+	}
+	return now.Sub(sf.start)%(sf.up+sf.down) < sf.up
+}
+
+// Delay is how long to wait before answering
+func (sf *SeedFault) Delay() time.Duration {
+	return sf.delay
+}
+
+// Status is the http error to answer with, zero if the request is answered
+func (sf *SeedFault) Status() int {
+	return sf.status
+}
+
+var malformedSeeds = []string{
+	"not-an-address",
+	"127.0.0.1",
+	"127.0.0.1:port",
+	"256.256.256.256:8111",
+	":::8111",
+	"127.0.0.1:99999",
+	"\x00\xff\xfe",
+	"# comment",
+	"127.0.0.1:8111 127.0.0.1:8112",
+}
+
+// Apply changes the list of seeds that is sent
+func (sf *SeedFault) Apply(seeds []string) []string {
+	switch sf.Mode {
+	case "empty":
+		return nil
+	case "malformed":
+		out := make([]string, 0, len(seeds)+len(malformedSeeds))
+		out = append(out, seeds...)
+		out = append(out, malformedSeeds...)
+		rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+		return out
+	case "unreachable":
+		// 192.0.2.0/24 is reserved for documentation and never answers
+		out := make([]string, 0, sf.count+len(seeds))
+		for i := 1; i <= sf.count; i++ {
+			out = append(out, fmt.Sprintf("192.0.2.%d:%d", i, 8111+rand.Intn(1000)))
+		}
+		return append(out, seeds...)
+	}
+	return seeds
+}
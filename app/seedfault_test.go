@@ -0,0 +1,83 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSeedFault(t *testing.T) {
+	tests := []struct {
+		mode, params string
+		ok           bool
+	}{
+		{"", "", true},
+		{"slow", "", true},
+		{"slow", "2s", true},
+		{"slow", "2", false},
+		{"error", "503", true},
+		{"error", "200", false},
+		{"unreachable", "0", false},
+		{"flapping", "10s,5s", true},
+		{"flapping", "10s", false},
+		{"flapping", "0s,5s", false},
+		{"broken", "", false},
+	}
+	for _, tt := range tests {
+		if _, err := NewSeedFault(tt.mode, tt.params); (err == nil) != tt.ok {
+			t.Errorf("NewSeedFault(%q, %q) error = %v, want ok %v", tt.mode, tt.params, err, tt.ok)
+		}
+	}
+}
+
+func TestSeedFault_Apply(t *testing.T) {
+	seeds := []string{"127.0.0.1:8111", "127.0.0.1:8112"}
+
+	none, _ := NewSeedFault("none", "")
+	if got := none.Apply(seeds); len(got) != 2 || none.Delay() != 0 || none.Status() != 0 {
+		t.Errorf("none changed the answer: %v", got)
+	}
+
+	empty, _ := NewSeedFault("empty", "")
+	if got := empty.Apply(seeds); len(got) != 0 {
+		t.Errorf("empty sent %v", got)
+	}
+
+	malformed, _ := NewSeedFault("malformed", "")
+	if got := malformed.Apply(seeds); len(got) != len(seeds)+len(malformedSeeds) {
+		t.Errorf("malformed sent %d lines, want %d", len(got), len(seeds)+len(malformedSeeds))
+	}
+
+	unreachable, _ := NewSeedFault("unreachable", "3")
+	got := unreachable.Apply(seeds)
+	if len(got) != 5 || got[3] != seeds[0] {
+		t.Fatalf("unreachable sent %v", got)
+	}
+	for _, addr := range got[:3] {
+		if !strings.HasPrefix(addr, "192.0.2.") {
+			t.Errorf("unreachable address %s is not in 192.0.2.0/24", addr)
+		}
+	}
+
+	slow, _ := NewSeedFault("slow", "3s")
+	if slow.Delay() != 3*time.Second {
+		t.Errorf("slow delay = %s, want 3s", slow.Delay())
+	}
+	e, _ := NewSeedFault("error", "")
+	if e.Status() != 500 {
+		t.Errorf("error status = %d, want 500", e.Status())
+	}
+}
+
+func TestSeedFault_Available(t *testing.T) {
+	sf, _ := NewSeedFault("flapping", "10s,5s")
+	tests := []struct {
+		offset time.Duration
+		want   bool
+	}{{0, true}, {9 * time.Second, true}, {10 * time.Second, false}, {14 * time.Second, false}, {15 * time.Second, true}, {40 * time.Second, false}}
+	for _, tt := range tests {
+		if got := sf.Available(sf.start.Add(tt.offset)); got != tt.want {
+			t.Errorf("Available(+%s) = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SeedEntry is a line of the seed list: the address of a node and,
+// optionally, its name
+type SeedEntry struct {
+	Name    string
+	Address string
+}
+
+// ParseSeeds reads a seed list with one address per line. An address may be
+// preceded by the name of the node, separated by a space.
+func ParseSeeds(content string) []SeedEntry {
+	var seeds []SeedEntry
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			seeds = append(seeds, SeedEntry{Address: fields[0]})
+		case 2:
+			seeds = append(seeds, SeedEntry{Name: fields[0], Address: fields[1]})
+		}
+	}
+	return seeds
+}
+
+var validTopologies = []string{"static", "ring", "line", "star", "random-regular", "small-world", "mainnet-snapshot"}
+
+// TopologyNames returns the available seed topologies
+func TopologyNames() []string {
+	return validTopologies
+}
+
+// SeedTopology decides which part of the seed list each node gets. The graph
+// is generated once, so every request of the same node is answered the same.
+// Peer sharing of the libraries can still add connections beyond the template.
+type SeedTopology struct {
+	Name  string
+	seeds []SeedEntry
+	adj   [][]int // nil for static
+}
+
+// NewSeedTopology creates the topology with the given name over the seeds,
+// in the order they are listed
+//
+//	static: every node gets the full list, the original behavior
+//	ring: every node gets the one before and after it
+//	line: like a ring without the connection between the last and the first
+//	star: the first node gets everyone, everyone else gets the first node
+//	random-regular: params is "degree[,rngseed]", every node gets degree random nodes
+//	small-world: params is "degree,rewire[,rngseed]", a ring where every node gets
+//	             degree/2 neighbors on each side and a share of rewire of them is
+//	             replaced by random nodes
+//	mainnet-snapshot: params is a recorded graph, either the DOT export of the
+//	             topology or one "a b" connection per line. Its nodes are matched
+//	             by name, the rest in order.
+func NewSeedTopology(name, params string, seeds []SeedEntry) (*SeedTopology, error) {
+	st := &SeedTopology{Name: name, seeds: seeds}
+	n := len(seeds)
+
+	var edges [][2]int
+	switch name {
+	case "", "static":
+		st.Name = "static"
+		return st, nil
+	case "ring":
+		for i := 0; i < n; i++ {
+			edges = append(edges, [2]int{i, (i + 1) % n})
+		}
+	case "line":
+		for i := 0; i+1 < n; i++ {
+			edges = append(edges, [2]int{i, i + 1})
+		}
+	case "star":
+		for i := 1; i < n; i++ {
+			edges = append(edges, [2]int{0, i})
+		}
+	case "random-regular":
+		vals, err := parseTopologyParams(params, []float64{4, 1})
+		if err != nil {
+			return nil, err
+		}
+		degree := int(vals[0])
+		if degree < 1 || degree >= n || n*degree%2 != 0 {
+			return nil, fmt.Errorf("random-regular needs 1 <= degree < %d nodes and an even nodes * degree", n)
+		}
+		edges = randomRegular(n, degree, rand.New(rand.NewSource(int64(vals[1]))))
+	case "small-world":
+		vals, err := parseTopologyParams(params, []float64{4, 0.1, 1})
+		if err != nil {
+			return nil, err
+		}
+		degree := int(vals[0])
+		if degree < 2 || degree%2 != 0 || degree >= n {
+			return nil, fmt.Errorf("small-world needs an even degree with 2 <= degree < %d nodes", n)
+		}
+		if vals[1] < 0 || vals[1] > 1 {
+			return nil, fmt.Errorf("rewire has to be between 0 and 1")
+		}
+		edges = smallWorld(n, degree, vals[1], rand.New(rand.NewSource(int64(vals[2]))))
+	case "mainnet-snapshot":
+		var err error
+		if edges, err = snapshotEdges(params, seeds); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown topology \"%s\"", name)
+	}
+
+	sets := make([]map[int]bool, n)
+	for i := range sets {
+		sets[i] = make(map[int]bool)
+	}
+	for _, e := range edges {
+		if e[0] != e[1] {
+			sets[e[0]][e[1]] = true
+			sets[e[1]][e[0]] = true
+		}
+	}
+	st.adj = make([][]int, n)
+	for i, set := range sets {
+		st.adj[i] = make([]int, 0, len(set))
+		for j := range set {
+			st.adj[i] = append(st.adj[i], j)
+		}
+		sort.Ints(st.adj[i])
+	}
+	return st, nil
+}
+
+// parseTopologyParams reads comma separated numbers, missing ones are taken
+// from the defaults
+func parseTopologyParams(params string, defaults []float64) ([]float64, error) {
+	vals := append([]float64(nil), defaults...)
+	if params = strings.TrimSpace(params); params == "" {
+		return vals, nil
+	}
+	split := strings.Split(params, ",")
+	if len(split) > len(vals) {
+		return nil, fmt.Errorf("expected at most %d parameters", len(vals))
+	}
+	for i, s := range split {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// randomRegular pairs up degree stubs of every node at random. Attempts with
+// loops or double connections are repeated, if all fail, those pairs are
+// dropped and a few nodes end up with a lower degree.
+func randomRegular(n, degree int, rng *rand.Rand) [][2]int {
+	stubs := make([]int, 0, n*degree)
+	for i := 0; i < n; i++ {
+		for d := 0; d < degree; d++ {
+			stubs = append(stubs, i)
+		}
+	}
+
+	var edges [][2]int
+	for attempt := 0; attempt < 100; attempt++ {
+		rng.Shuffle(len(stubs), func(i, j int) { stubs[i], stubs[j] = stubs[j], stubs[i] })
+		edges = edges[:0]
+		seen := make(map[[2]int]bool)
+		valid := true
+		for i := 0; i < len(stubs); i += 2 {
+			a, b := stubs[i], stubs[i+1]
+			if a > b {
+				a, b = b, a
+			}
+			if a == b || seen[[2]int{a, b}] {
+				valid = false
+				continue
+			}
+			seen[[2]int{a, b}] = true
+			edges = append(edges, [2]int{a, b})
+		}
+		if valid {
+			break
+		}
+	}
+	return edges
+}
+
+// smallWorld builds a Watts-Strogatz graph
+func smallWorld(n, degree int, rewire float64, rng *rand.Rand) [][2]int {
+	has := make(map[[2]int]bool)
+	key := func(a, b int) [2]int {
+		if a > b {
+			return [2]int{b, a}
+		}
+		return [2]int{a, b}
+	}
+	for i := 0; i < n; i++ {
+		for j := 1; j <= degree/2; j++ {
+			has[key(i, (i+j)%n)] = true
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 1; j <= degree/2; j++ {
+			if rng.Float64() >= rewire {
+				continue
+			}
+			target := rng.Intn(n)
+			if target == i || has[key(i, target)] {
+				continue
+			}
+			delete(has, key(i, (i+j)%n))
+			has[key(i, target)] = true
+		}
+	}
+
+	edges := make([][2]int, 0, len(has))
+	for e := range has {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+	return edges
+}
+
+var dotEdge = regexp.MustCompile(`^"?([^"\s]+)"?\s*--\s*"?([^"\s;]+)"?;?$`)
+
+// snapshotEdges maps a recorded graph onto the seeds. Nodes of the snapshot
+// that have the name of a seed get that seed, the others get the remaining
+// seeds in the order of their names.
+func snapshotEdges(snapshot string, seeds []SeedEntry) ([][2]int, error) {
+	var pairs [][2]string
+	nodes := make(map[string]bool)
+	for _, line := range strings.Split(snapshot, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "graph") || line == "}" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var a, b string
+		if m := dotEdge.FindStringSubmatch(line); m != nil {
+			a, b = m[1], m[2]
+		} else if fields := strings.Fields(line); len(fields) == 2 {
+			a, b = fields[0], fields[1]
+		} else {
+			continue // a node declaration
+		}
+		nodes[a] = true
+		nodes[b] = true
+		pairs = append(pairs, [2]string{a, b})
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("the snapshot has no connections")
+	}
+	if len(nodes) > len(seeds) {
+		return nil, fmt.Errorf("the snapshot has %d nodes but there are only %d seeds", len(nodes), len(seeds))
+	}
+
+	index := make(map[string]int)
+	used := make([]bool, len(seeds))
+	for i, s := range seeds {
+		if s.Name != "" && nodes[s.Name] {
+			index[s.Name] = i
+			used[i] = true
+		}
+	}
+	var rest []string
+	for name := range nodes {
+		if _, ok := index[name]; !ok {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	next := 0
+	for _, name := range rest {
+		for used[next] {
+			next++
+		}
+		index[name] = next
+		used[next] = true
+	}
+
+	edges := make([][2]int, 0, len(pairs))
+	for _, p := range pairs {
+		edges = append(edges, [2]int{index[p[0]], index[p[1]]})
+	}
+	return edges, nil
+}
+
+// find returns the position of the node in the seed list. The name is
+// preferred over the port.
+func (st *SeedTopology) find(name, port string) int {
+	if name != "" {
+		for i, s := range st.seeds {
+			if s.Name == name {
+				return i
+			}
+		}
+	}
+	if port != "" {
+		for i, s := range st.seeds {
+			if _, p, err := net.SplitHostPort(s.Address); err == nil && p == port {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Seeds returns the addresses the node with the given name or port gets.
+// Unknown nodes get the full list.
+func (st *SeedTopology) Seeds(name, port string) []string {
+	i := -1
+	if st.adj != nil {
+		i = st.find(name, port)
+	}
+	if i < 0 {
+		all := make([]string, len(st.seeds))
+		for j, s := range st.seeds {
+			all[j] = s.Address
+		}
+		return all
+	}
+	addrs := make([]string, len(st.adj[i]))
+	for j, k := range st.adj[i] {
+		addrs[j] = st.seeds[k].Address
+	}
+	return addrs
+}
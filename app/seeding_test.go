@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSeeds(n int) []SeedEntry {
+	seeds := make([]SeedEntry, n)
+	for i := range seeds {
+		seeds[i] = SeedEntry{Name: fmt.Sprintf("n%d", i), Address: fmt.Sprintf("127.0.0.1:%d", 8111+i)}
+	}
+	return seeds
+}
+
+func TestParseSeeds(t *testing.T) {
+	seeds := ParseSeeds("127.0.0.1:8111\n\n  Node1 127.0.0.1:8112  \nbad line here\n")
+	want := []SeedEntry{{Address: "127.0.0.1:8111"}, {Name: "Node1", Address: "127.0.0.1:8112"}}
+	if !reflect.DeepEqual(seeds, want) {
+		t.Errorf("ParseSeeds() = %v, want %v", seeds, want)
+	}
+}
+
+func TestSeedTopology_Seeds(t *testing.T) {
+	seeds := testSeeds(5)
+	tests := []struct {
+		topology string
+		node     int
+		want     []int
+	}{
+		{"ring", 0, []int{1, 4}},
+		{"ring", 2, []int{1, 3}},
+		{"line", 0, []int{1}},
+		{"line", 4, []int{3}},
+		{"star", 0, []int{1, 2, 3, 4}},
+		{"star", 3, []int{0}},
+	}
+	for _, tt := range tests {
+		st, err := NewSeedTopology(tt.topology, "", seeds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]string, len(tt.want))
+		for i, w := range tt.want {
+			want[i] = seeds[w].Address
+		}
+		if got := st.Seeds(seeds[tt.node].Name, ""); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: node %d got %v, want %v", tt.topology, tt.node, got, want)
+		}
+	}
+
+	st, _ := NewSeedTopology("ring", "", seeds)
+	if got := st.Seeds("", "8113"); !reflect.DeepEqual(got, []string{seeds[1].Address, seeds[3].Address}) {
+		t.Errorf("lookup by port got %v", got)
+	}
+	if got := st.Seeds("unknown", "1"); len(got) != len(seeds) {
+		t.Errorf("unknown node got %v, want the full list", got)
+	}
+	st, _ = NewSeedTopology("static", "", seeds)
+	if got := st.Seeds("n1", ""); len(got) != len(seeds) {
+		t.Errorf("static got %v, want the full list", got)
+	}
+}
+
+func degrees(st *SeedTopology) []int {
+	d := make([]int, len(st.adj))
+	for i, adj := range st.adj {
+		d[i] = len(adj)
+		for _, j := range adj {
+			found := false
+			for _, k := range st.adj[j] {
+				found = found || k == i
+			}
+			if !found || j == i {
+				d[i] = -1
+			}
+		}
+	}
+	return d
+}
+
+func TestSeedTopology_Random(t *testing.T) {
+	st, err := NewSeedTopology("random-regular", "4,7", testSeeds(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range degrees(st) {
+		if d != 4 {
+			t.Errorf("random-regular node %d has degree %d, want 4", i, d)
+		}
+	}
+	again, _ := NewSeedTopology("random-regular", "4,7", testSeeds(20))
+	if !reflect.DeepEqual(st.adj, again.adj) {
+		t.Errorf("the same rng seed produced different graphs")
+	}
+
+	st, err = NewSeedTopology("small-world", "4,0.2", testSeeds(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for i, d := range degrees(st) {
+		if d < 1 {
+			t.Errorf("small-world node %d has degree %d", i, d)
+		}
+		sum += d
+	}
+	if sum != 20*4 {
+		t.Errorf("small-world has %d edges, want %d", sum/2, 20*2)
+	}
+
+	if _, err := NewSeedTopology("random-regular", "3", testSeeds(5)); err == nil {
+		t.Errorf("odd number of stubs was accepted")
+	}
+	if _, err := NewSeedTopology("small-world", "3,0.1", testSeeds(10)); err == nil {
+		t.Errorf("odd small-world degree was accepted")
+	}
+	if _, err := NewSeedTopology("mesh", "", testSeeds(10)); err == nil {
+		t.Errorf("unknown topology was accepted")
+	}
+}
+
+func TestSeedTopology_Snapshot(t *testing.T) {
+	g := BuildGraph([]NodeInfo{
+		{Name: "n2", LastSeen: time.Now(), Peers: []string{"x", "y"}},
+		{Name: "x", LastSeen: time.Now()},
+		{Name: "y", LastSeen: time.Now(), Peers: []string{"x"}},
+	})
+	seeds := testSeeds(4)
+	st, err := NewSeedTopology("mainnet-snapshot", g.DOT(), seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// n2 keeps its seed, x and y get n0 and n1
+	want := [][]int{{1, 2}, {0, 2}, {0, 1}, {}}
+	if !reflect.DeepEqual(st.adj, want) {
+		t.Errorf("snapshot adjacency = %v, want %v", st.adj, want)
+	}
+
+	plain, err := NewSeedTopology("mainnet-snapshot", "n2 x\nn2 y\ny x", seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plain.adj, st.adj) {
+		t.Errorf("edge list = %v, DOT = %v", plain.adj, st.adj)
+	}
+
+	if _, err := NewSeedTopology("mainnet-snapshot", "a b\nb c\nc d\nd e", seeds); err == nil {
+		t.Errorf("snapshot with more nodes than seeds was accepted")
+	}
+}
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type registration struct {
+	entry SeedEntry
+	order uint64
+	first time.Time
+	seen  time.Time
+}
+
+// SeedList is the live set of seeds: the static seeds and the nodes that
+// registered themselves. Registered nodes that stop heartbeating expire.
+type SeedList struct {
+	mtx        sync.Mutex
+	static     []SeedEntry
+	registered map[string]*registration
+	order      uint64
+	version    uint64
+
+	// Expiry is how long a registration lasts without a heartbeat, zero
+	// keeps it forever
+	Expiry time.Duration
+	// Shuffle randomizes the order of every answer
+	Shuffle bool
+	// Subset limits every answer to this many seeds, zero sends all
+	Subset int
+	// Advertise is the host that replaces loopback addresses, see Rewrite
+	Advertise string
+
+	topology, params string
+	cached           *SeedTopology
+	cachedVersion    uint64
+}
+
+// NewSeedList creates a seed list that hands out the seeds according to the
+// topology. The topology is rebuilt whenever the set of seeds changes.
+func NewSeedList(static []SeedEntry, topology, params string) (*SeedList, error) {
+	if !validTopology(topology) {
+		return nil, fmt.Errorf("unknown topology \"%s\"", topology)
+	}
+	if len(static) > 0 {
+		if _, err := NewSeedTopology(topology, params, static); err != nil {
+			return nil, err
+		}
+	}
+	sl := new(SeedList)
+	sl.static = static
+	sl.registered = make(map[string]*registration)
+	sl.topology = topology
+	sl.params = params
+	sl.version = 1
+	return sl, nil
+}
+
+func validTopology(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, t := range validTopologies {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Register adds a node or renews its registration
+func (sl *SeedList) Register(name, address string) {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	r, ok := sl.registered[address]
+	if !ok {
+		sl.order++
+		r = &registration{order: sl.order, first: time.Now()}
+		sl.registered[address] = r
+		sl.version++
+	} else if r.entry.Name != name {
+		sl.version++
+	}
+	r.entry = SeedEntry{Name: name, Address: address}
+	r.seen = time.Now()
+}
+
+// live removes expired registrations and returns the static seeds followed
+// by the registered nodes in the order they first registered. Expects the
+// lock to be held.
+func (sl *SeedList) live() []SeedEntry {
+	if sl.Expiry > 0 {
+		cutoff := time.Now().Add(-sl.Expiry)
+		for addr, r := range sl.registered {
+			if r.seen.Before(cutoff) {
+				delete(sl.registered, addr)
+				sl.version++
+			}
+		}
+	}
+
+	regs := make([]*registration, 0, len(sl.registered))
+	for _, r := range sl.registered {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].order < regs[j].order
+	})
+
+	entries := make([]SeedEntry, 0, len(sl.static)+len(regs))
+	entries = append(entries, sl.static...)
+	for _, r := range regs {
+		if !sl.isStatic(r.entry.Address) {
+			entries = append(entries, r.entry)
+		}
+	}
+	return entries
+}
+
+// isStatic compares the addresses after rewriting them, so a static loopback
+// seed and the registration of the same node are the same seed
+func (sl *SeedList) isStatic(address string) bool {
+	address = sl.rewritten(address)
+	for _, s := range sl.static {
+		if sl.rewritten(s.Address) == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (sl *SeedList) rewritten(address string) string {
+	if r, err := sl.Rewrite(address); err == nil {
+		return r
+	}
+	return address
+}
+
+// SeedStatus is a seed as listed by the seed server's api. The registration
+// times of static seeds are zero.
+type SeedStatus struct {
+	Name       string    `json:"name,omitempty"`
+	Address    string    `json:"address"`
+	Static     bool      `json:"static"`
+	Registered time.Time `json:"registered"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+// Status lists the current set of seeds in the order they are handed out
+func (sl *SeedList) Status() []SeedStatus {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	entries := sl.live()
+	status := make([]SeedStatus, len(entries))
+	for i, e := range entries {
+		status[i] = SeedStatus{Name: e.Name, Address: e.Address}
+		if r, ok := sl.registered[e.Address]; ok && !sl.isStatic(e.Address) {
+			status[i].Registered = r.first
+			status[i].LastSeen = r.seen
+		} else {
+			status[i].Static = true
+		}
+	}
+	return status
+}
+
+// AddStatic adds a seed that doesn't expire, or renames it if it exists
+func (sl *SeedList) AddStatic(entry SeedEntry) {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	for i, s := range sl.static {
+		if s.Address == entry.Address {
+			sl.static[i] = entry
+			sl.version++
+			return
+		}
+	}
+	sl.static = append(sl.static, entry)
+	sl.version++
+}
+
+// SetStatic replaces all static seeds
+func (sl *SeedList) SetStatic(entries []SeedEntry) {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	sl.static = entries
+	sl.version++
+}
+
+// Remove deletes the seed with the address, static or registered. Returns
+// false if there is no such seed.
+func (sl *SeedList) Remove(address string) bool {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	found := false
+	for i, s := range sl.static {
+		if s.Address == address {
+			sl.static = append(sl.static[:i:i], sl.static[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if _, ok := sl.registered[address]; ok {
+		delete(sl.registered, address)
+		found = true
+	}
+	if found {
+		sl.version++
+	}
+	return found
+}
+
+// Live returns the current set of seeds
+func (sl *SeedList) Live() []SeedEntry {
+	sl.mtx.Lock()
+	defer sl.mtx.Unlock()
+	return sl.live()
+}
+
+// Seeds returns the addresses the node with the given name or port gets. If
+// there are too few seeds for the topology yet, everyone gets the full list.
+func (sl *SeedList) Seeds(name, port string) []string {
+	sl.mtx.Lock()
+	entries := sl.live()
+	if sl.cached == nil || sl.cachedVersion != sl.version {
+		st, err := NewSeedTopology(sl.topology, sl.params, entries)
+		if err != nil {
+			st, _ = NewSeedTopology("static", "", entries)
+		}
+		sl.cached = st
+		sl.cachedVersion = sl.version
+	}
+	st := sl.cached
+	shuffle, subset := sl.Shuffle, sl.Subset
+	sl.mtx.Unlock()
+
+	seeds := st.Seeds(name, port)
+	if shuffle {
+		rand.Shuffle(len(seeds), func(i, j int) { seeds[i], seeds[j] = seeds[j], seeds[i] })
+	}
+	if subset > 0 && len(seeds) > subset {
+		seeds = seeds[:subset]
+	}
+	return seeds
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Rewrite checks the address and replaces a loopback host with the advertised
+// host. Nodes on the same machine as the seed server register over loopback,
+// which remote nodes can't dial. Without an advertised host the address is
+// kept, which only works if every node runs on this machine.
+func (sl *SeedList) Rewrite(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", fmt.Errorf("invalid port \"%s\"", port)
+	}
+	if sl.Advertise != "" && isLoopback(host) {
+		return net.JoinHostPort(sl.Advertise, port), nil
+	}
+	return addr, nil
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSeedList_Register(t *testing.T) {
+	sl, err := NewSeedList([]SeedEntry{{Address: "10.0.0.1:8111"}}, "static", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl.Register("b", "10.0.0.3:8111")
+	sl.Register("a", "10.0.0.2:8111")
+	sl.Register("static", "10.0.0.1:8111")
+	sl.Register("b", "10.0.0.3:8111")
+
+	want := []string{"10.0.0.1:8111", "10.0.0.3:8111", "10.0.0.2:8111"}
+	if got := sl.Seeds("", ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Seeds() = %v, want %v", got, want)
+	}
+
+	sl.Expiry = time.Minute
+	sl.registered["10.0.0.3:8111"].seen = time.Now().Add(-2 * time.Minute)
+	want = []string{"10.0.0.1:8111", "10.0.0.2:8111"}
+	if got := sl.Seeds("", ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Seeds() after expiry = %v, want %v", got, want)
+	}
+}
+
+func TestSeedList_Topology(t *testing.T) {
+	sl, err := NewSeedList(nil, "ring", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl.Register("n0", "10.0.0.1:8111")
+	sl.Register("n1", "10.0.0.2:8111")
+	sl.Register("n2", "10.0.0.3:8111")
+	if got := sl.Seeds("n0", ""); !reflect.DeepEqual(got, []string{"10.0.0.2:8111", "10.0.0.3:8111"}) {
+		t.Errorf("ring of three got %v", got)
+	}
+
+	sl.Register("n3", "10.0.0.4:8111")
+	if got := sl.Seeds("n0", ""); !reflect.DeepEqual(got, []string{"10.0.0.2:8111", "10.0.0.4:8111"}) {
+		t.Errorf("ring was not rebuilt after registration, got %v", got)
+	}
+
+	sl.Subset = 1
+	if got := sl.Seeds("n0", ""); len(got) != 1 {
+		t.Errorf("subset of 1 got %v", got)
+	}
+
+	// too few nodes for the degree yet
+	rr, err := NewSeedList(nil, "random-regular", "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Register("n0", "10.0.0.1:8111")
+	rr.Register("n1", "10.0.0.2:8111")
+	if got := rr.Seeds("n0", ""); len(got) != 2 {
+		t.Errorf("random-regular with two nodes got %v, want the full list", got)
+	}
+
+	if _, err := NewSeedList(nil, "mesh", ""); err == nil {
+		t.Errorf("unknown topology was accepted")
+	}
+}
+
+func TestSeedList_Edit(t *testing.T) {
+	sl, _ := NewSeedList(ParseSeeds("a 10.0.0.1:8111\n10.0.0.2:8111"), "ring", "")
+	sl.Register("c", "10.0.0.3:8111")
+	sl.AddStatic(SeedEntry{Name: "d", Address: "10.0.0.4:8111"})
+	sl.AddStatic(SeedEntry{Name: "b", Address: "10.0.0.2:8111"})
+
+	status := sl.Status()
+	var names []string
+	for _, s := range status {
+		names = append(names, s.Name)
+	}
+	if want := []string{"a", "b", "d", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("seeds = %v, want %v", names, want)
+	}
+	if !status[0].Static || status[3].Static || status[3].Registered.IsZero() {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if got := sl.Seeds("a", ""); !reflect.DeepEqual(got, []string{"10.0.0.2:8111", "10.0.0.3:8111"}) {
+		t.Errorf("ring after edits got %v", got)
+	}
+
+	if !sl.Remove("10.0.0.3:8111") || !sl.Remove("10.0.0.2:8111") || sl.Remove("10.0.0.9:8111") {
+		t.Errorf("Remove() did not find the right seeds")
+	}
+	if got := sl.Seeds("a", ""); !reflect.DeepEqual(got, []string{"10.0.0.4:8111"}) {
+		t.Errorf("ring after removal got %v", got)
+	}
+
+	sl.SetStatic(ParseSeeds("10.0.0.5:8111"))
+	if got := sl.Live(); len(got) != 1 || got[0].Address != "10.0.0.5:8111" {
+		t.Errorf("SetStatic() left %v", got)
+	}
+}
+
+func TestSeedList_Rewrite(t *testing.T) {
+	sl, err := NewSeedList([]SeedEntry{{Address: "127.0.0.1:8111"}}, "static", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		advertise, addr, want string
+		err                   bool
+	}{
+		{"", "10.0.0.2:8111", "10.0.0.2:8111", false},
+		{"", "127.0.0.1:8111", "127.0.0.1:8111", false},
+		{"192.168.1.5", "10.0.0.2:8111", "10.0.0.2:8111", false},
+		{"192.168.1.5", "127.0.0.1:8111", "192.168.1.5:8111", false},
+		{"192.168.1.5", "[::1]:8112", "192.168.1.5:8112", false},
+		{"seed.example.com", "localhost:8113", "seed.example.com:8113", false},
+		{"192.168.1.5", "10.0.0.2", "", true},
+		{"192.168.1.5", "10.0.0.2:port", "", true},
+	}
+	for _, tt := range tests {
+		sl.Advertise = tt.advertise
+		got, err := sl.Rewrite(tt.addr)
+		if (err != nil) != tt.err {
+			t.Errorf("Rewrite(%q) with %q error = %v, want error %v", tt.addr, tt.advertise, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Rewrite(%q) with %q = %q, want %q", tt.addr, tt.advertise, got, tt.want)
+		}
+	}
+
+	// the registration of the static loopback seed isn't served twice
+	sl.Advertise = "192.168.1.5"
+	sl.Register("n0", "192.168.1.5:8111")
+	sl.Register("n1", "192.168.1.5:8112")
+	want := []string{"127.0.0.1:8111", "192.168.1.5:8112"}
+	if got := sl.Seeds("", ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Seeds() = %v, want %v", got, want)
+	}
+}
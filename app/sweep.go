@@ -0,0 +1,332 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+	"github.com/rs/zerolog/log"
+)
+
+// SweepConfig describes a fanout sweep: the same load is run at every fanout
+// in turn. p2p1 nodes change the library's broadcast fanout (-broadcast),
+// p2p2 can't change it at runtime and falls back to the app level "fanout"
+// relay policy. If Start is set, the sweep starts the load itself and stops
+// it once it's done.
+type SweepConfig struct {
+	Fanouts []int
+	Settle  time.Duration
+	Measure time.Duration
+
+	Start               bool
+	EPS, Feds, Audits   int
+	Arrival, Mode, Spec string
+}
+
+// ParseFanouts reads a comma separated list of fanouts
+func ParseFanouts(s string) ([]int, error) {
+	var fanouts []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		if v < 1 {
+			return nil, fmt.Errorf("fanout has to be at least 1")
+		}
+		fanouts = append(fanouts, v)
+	}
+	if len(fanouts) == 0 {
+		return nil, fmt.Errorf("no fanouts specified")
+	}
+	return fanouts, nil
+}
+
+// SweepPoint is what the cluster did during the measurement of one fanout
+type SweepPoint struct {
+	Fanout     int
+	Nodes      int
+	Originated uint64
+	Received   uint64
+	Unique     uint64
+	BytesUp    uint64
+}
+
+// Delivery is the share of generated messages that arrived at each node
+func (sp SweepPoint) Delivery() float64 {
+	if sp.Originated == 0 || sp.Nodes == 0 {
+		return 0
+	}
+	d := float64(sp.Unique) / float64(sp.Originated*uint64(sp.Nodes))
+	if d > 1 {
+		d = 1
+	}
+	return d
+}
+
+// Dupes is the share of received messages that were duplicates
+func (sp SweepPoint) Dupes() float64 {
+	if sp.Received == 0 {
+		return 0
+	}
+	return float64(sp.Received-sp.Unique) / float64(sp.Received)
+}
+
+func (sp SweepPoint) BytesUpF() string {
+	return network.PrettyBytes(sp.BytesUp) + "/s"
+}
+
+// measurePoint compares the nodes before and after the measurement. Only nodes
+// present at both times count. Bandwidth is the average of the cluster's
+// total upload samples.
+func measurePoint(fanout int, before, after []NodeInfo, bandwidth []uint64) SweepPoint {
+	sp := SweepPoint{Fanout: fanout}
+	start := make(map[string]NodeInfo)
+	for _, ni := range before {
+		start[ni.Name] = ni
+	}
+	for _, ni := range after {
+		prev, ok := start[ni.Name]
+		if !ok || !ni.Active() {
+			continue
+		}
+		sp.Nodes++
+		sp.Originated += ni.Originated - prev.Originated
+		sp.Received += ni.Received - prev.Received
+		sp.Unique += ni.Unique - prev.Unique
+	}
+	if len(bandwidth) > 0 {
+		var sum uint64
+		for _, b := range bandwidth {
+			sum += b
+		}
+		sp.BytesUp = sum / uint64(len(bandwidth))
+	}
+	return sp
+}
+
+// SweepStats is the progress and the results of the last fanout sweep
+type SweepStats struct {
+	Running bool
+	Status  string
+	Started time.Time
+	Fanouts []int
+	Points  []SweepPoint
+}
+
+// Plot returns the points of an svg polyline of the metric ("delivery",
+// "dupes", or "bandwidth") against fanout. Fanouts are spaced evenly.
+func (ss SweepStats) Plot(metric string, width, height int) string {
+	var max uint64
+	for _, p := range ss.Points {
+		if p.BytesUp > max {
+			max = p.BytesUp
+		}
+	}
+
+	steps := len(ss.Fanouts) - 1
+	if steps < 1 {
+		steps = 1
+	}
+
+	coords := make([]string, 0, len(ss.Points))
+	for i, p := range ss.Points {
+		var v float64
+		switch metric {
+		case "delivery":
+			v = p.Delivery()
+		case "dupes":
+			v = p.Dupes()
+		case "bandwidth":
+			if max > 0 {
+				v = float64(p.BytesUp) / float64(max)
+			}
+		}
+		x := float64(width) * float64(i) / float64(steps)
+		y := float64(height) * (1 - v)
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(coords, " ")
+}
+
+// X is the horizontal position of the i-th fanout in a plot
+func (ss SweepStats) X(i, width int) int {
+	if len(ss.Fanouts) < 2 {
+		return 0
+	}
+	return width * i / (len(ss.Fanouts) - 1)
+}
+
+// MaxBytesUpF is the highest bandwidth of the sweep, the top of the bandwidth plot
+func (ss SweepStats) MaxBytesUpF() string {
+	var max uint64
+	for _, p := range ss.Points {
+		if p.BytesUp > max {
+			max = p.BytesUp
+		}
+	}
+	return network.PrettyBytes(max) + "/s"
+}
+
+// Sweep runs one fanout sweep at a time
+type Sweep struct {
+	mtx   sync.RWMutex
+	stats SweepStats
+	stop  chan bool
+}
+
+func (s *Sweep) setStatus(status string) {
+	s.mtx.Lock()
+	s.stats.Status = status
+	s.mtx.Unlock()
+	log.Info().Str("status", status).Msg("fanout sweep")
+}
+
+func (s *Sweep) add(sp SweepPoint) {
+	s.mtx.Lock()
+	s.stats.Points = append(s.stats.Points, sp)
+	s.mtx.Unlock()
+}
+
+// StartSweep runs the load at each fanout of the config across the entire
+// network, one after the other
+func (a *App) StartSweep(cfg SweepConfig) error {
+	if len(cfg.Fanouts) == 0 {
+		return fmt.Errorf("no fanouts specified")
+	}
+	if cfg.Measure <= 0 {
+		return fmt.Errorf("measure duration has to be positive")
+	}
+
+	a.sweep.mtx.Lock()
+	defer a.sweep.mtx.Unlock()
+	if a.sweep.stats.Running {
+		return fmt.Errorf("sweep already running")
+	}
+	a.sweep.stats = SweepStats{
+		Running: true,
+		Status:  "starting",
+		Started: time.Now(),
+		Fanouts: cfg.Fanouts,
+	}
+	a.sweep.stop = make(chan bool)
+	go a.runSweep(cfg, a.sweep.stop)
+	return nil
+}
+
+// StopSweep aborts the running sweep. Points already measured are kept.
+func (a *App) StopSweep() {
+	a.sweep.mtx.Lock()
+	defer a.sweep.mtx.Unlock()
+	if a.sweep.stats.Running && a.sweep.stop != nil {
+		close(a.sweep.stop)
+		a.sweep.stop = nil
+	}
+}
+
+func (a *App) SweepStats() SweepStats {
+	a.sweep.mtx.RLock()
+	defer a.sweep.mtx.RUnlock()
+	ss := a.sweep.stats
+	ss.Points = append([]SweepPoint(nil), ss.Points...)
+	return ss
+}
+
+func (a *App) runSweep(cfg SweepConfig, stop chan bool) {
+	// every node gets its own policy back afterwards
+	policies := make(map[string][2]string)
+	for _, ni := range a.cluster.Nodes() {
+		if ni.Active() && ni.Relay != "" {
+			policies[ni.Name] = [2]string{ni.Relay, ni.RelayParams}
+		}
+	}
+	policy, params := a.relay.Policy()
+	policies[a.n.Name()] = [2]string{policy, params}
+
+	status := "done"
+	defer func() {
+		if err := a.SetFanout(-1); err != nil {
+			log.Error().Err(err).Msg("unable to restore fanout")
+		}
+		for node, p := range policies {
+			if err := a.setNodeRelay(node, p[0], p[1]); err != nil {
+				log.Error().Err(err).Str("node", node).Msg("unable to restore relay policy")
+			}
+		}
+		if cfg.Start {
+			if err := a.DistributeLoad(false, cfg.EPS, cfg.Feds, cfg.Audits, cfg.Arrival, cfg.Mode, cfg.Spec); err != nil {
+				log.Error().Err(err).Msg("unable to stop load")
+			}
+		}
+		a.sweep.setStatus(status)
+		a.sweep.mtx.Lock()
+		a.sweep.stats.Running = false
+		a.sweep.mtx.Unlock()
+	}()
+
+	wait := func(d time.Duration) bool {
+		select {
+		case <-stop:
+			status = "stopped"
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	// the generators need time to ramp up before the first measurement
+	ramp := time.Duration(0)
+	if cfg.Start {
+		if err := a.DistributeLoad(true, cfg.EPS, cfg.Feds, cfg.Audits, cfg.Arrival, cfg.Mode, cfg.Spec); err != nil {
+			status = fmt.Sprintf("unable to start load: %v", err)
+			cfg.Start = false
+			return
+		}
+		ramp = time.Duration(cfg.EPS/rampStep) * rampInterval
+	}
+
+	for _, fanout := range cfg.Fanouts {
+		if err := a.SetFanout(fanout); err != nil {
+			status = fmt.Sprintf("unable to set fanout %d: %v", fanout, err)
+			return
+		}
+
+		a.sweep.setStatus(fmt.Sprintf("fanout %d: settling", fanout))
+		if !wait(ramp + cfg.Settle) {
+			return
+		}
+		ramp = 0
+
+		a.sweep.setStatus(fmt.Sprintf("fanout %d: measuring", fanout))
+		before := a.cluster.Nodes()
+		var bandwidth []uint64
+		end := time.Now().Add(cfg.Measure)
+		for time.Now().Before(end) {
+			d := time.Until(end)
+			if d > sweepSampleInterval {
+				d = sweepSampleInterval
+			}
+			if !wait(d) {
+				return
+			}
+			var sum uint64
+			for _, ni := range a.cluster.Nodes() {
+				if ni.Active() {
+					sum += ni.BytesUp
+				}
+			}
+			bandwidth = append(bandwidth, sum)
+		}
+
+		sp := measurePoint(fanout, before, a.cluster.Nodes(), bandwidth)
+		log.Info().Int("fanout", fanout).Float64("delivery", sp.Delivery()).Float64("dupes", sp.Dupes()).Uint64("bytesup", sp.BytesUp).Msg("fanout sweep measured")
+		a.sweep.add(sp)
+	}
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/network"
+)
+
+func TestParseFanouts(t *testing.T) {
+	f, err := ParseFanouts("2, 4,8,,16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f) != 4 || f[0] != 2 || f[3] != 16 {
+		t.Errorf("unexpected fanouts %v", f)
+	}
+
+	for _, bad := range []string{"", "2,x", "0,2"} {
+		if _, err := ParseFanouts(bad); err == nil {
+			t.Errorf("no error for \"%s\"", bad)
+		}
+	}
+}
+
+func Test_measurePoint(t *testing.T) {
+	now := time.Now()
+	before := []NodeInfo{
+		{Name: "a", LastSeen: now, Originated: 100, Received: 100, Unique: 100},
+		{Name: "b", LastSeen: now, Originated: 0, Received: 50, Unique: 50},
+	}
+	after := []NodeInfo{
+		{Name: "a", LastSeen: now, Originated: 200, Received: 250, Unique: 200},
+		{Name: "b", LastSeen: now, Originated: 0, Received: 260, Unique: 130},
+		{Name: "c", LastSeen: now, Originated: 500, Received: 500, Unique: 500},
+	}
+
+	sp := measurePoint(4, before, after, []uint64{100, 300})
+	if sp.Nodes != 2 {
+		t.Errorf("nodes = %d, want 2", sp.Nodes)
+	}
+	if sp.Delivery() != 0.9 {
+		t.Errorf("delivery = %f, want 0.9", sp.Delivery())
+	}
+	if sp.Dupes() != 0.5 {
+		t.Errorf("dupes = %f, want 0.5", sp.Dupes())
+	}
+	if sp.BytesUp != 200 {
+		t.Errorf("bytes up = %d, want 200", sp.BytesUp)
+	}
+}
+
+// runtimeFanout is a network that can change its fanout while running
+type runtimeFanout struct {
+	*network.Loopback
+	fanout int
+}
+
+func (rf *runtimeFanout) SetFanout(fanout int) bool {
+	rf.fanout = fanout
+	return true
+}
+
+func TestApp_setFanout(t *testing.T) {
+	a := NewApp()
+	lb := network.NewLoopback(1)
+	a.n = lb
+	if err := a.setFanout(4); err != nil {
+		t.Fatal(err)
+	}
+	if policy, params := a.Relay(); policy != "fanout" || params != "*=4" {
+		t.Errorf("without runtime fanout the relay is %s %q, want the fanout policy", policy, params)
+	}
+
+	rf := &runtimeFanout{Loopback: lb}
+	a.n = rf
+	if err := a.setFanout(6); err != nil {
+		t.Fatal(err)
+	}
+	if policy, _ := a.Relay(); policy != "flood" || rf.fanout != 6 {
+		t.Errorf("with runtime fanout the relay is %s and the fanout %d, want flood and 6", policy, rf.fanout)
+	}
+	if err := a.setFanout(-1); err != nil {
+		t.Fatal(err)
+	}
+	if rf.fanout != 0 {
+		t.Errorf("restoring set the fanout to %d, want 0", rf.fanout)
+	}
+}
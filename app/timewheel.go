@@ -0,0 +1,152 @@
+package app
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// TimeWheel keeps an exact record of hashes in a single map. Every hash is
+// also added to the slot of the current interval and when the wheel turns,
+// the hashes of the oldest slot are removed from the map. Checking a hash
+// is a single map lookup instead of one per bucket.
+type TimeWheel struct {
+	interval time.Duration
+	stop     chan struct{}
+
+	mtx     sync.RWMutex
+	entries map[[32]byte]bool
+	slots   [][][32]byte
+	current int
+}
+
+var _ Deduplicator = (*TimeWheel)(nil)
+
+func NewTimeWheel(interval time.Duration, size uint) *TimeWheel {
+	tw := newTimeWheel(interval, size)
+	go tw.start()
+	return tw
+}
+
+func newTimeWheel(interval time.Duration, size uint) *TimeWheel {
+	tw := new(TimeWheel)
+	tw.interval = interval
+	tw.entries = make(map[[32]byte]bool)
+	tw.slots = make([][][32]byte, size)
+	tw.stop = make(chan struct{})
+	return tw
+}
+
+func (tw *TimeWheel) start() {
+	ticker := time.NewTicker(tw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tw.stop:
+			return
+		case <-ticker.C:
+			tw.turn()
+		}
+	}
+}
+
+// turn advances the wheel by one slot and forgets the hashes in it
+func (tw *TimeWheel) turn() {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	tw.current = (tw.current + 1) % len(tw.slots)
+	for _, h := range tw.slots[tw.current] {
+		delete(tw.entries, h)
+	}
+	tw.slots[tw.current] = nil
+}
+
+func (tw *TimeWheel) Dupe(hash [32]byte) bool {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+
+	if tw.entries[hash] {
+		return true
+	}
+	tw.entries[hash] = true
+	tw.slots[tw.current] = append(tw.slots[tw.current], hash)
+	return false
+}
+
+func (tw *TimeWheel) Len() int {
+	tw.mtx.RLock()
+	defer tw.mtx.RUnlock()
+	return len(tw.entries)
+}
+
+// timeWheelEntryBytes is the map entry of a hash plus its copy in the slot
+var timeWheelEntryBytes = mapEntryBytes(unsafe.Sizeof([32]byte{}), unsafe.Sizeof(true)) + uint64(unsafe.Sizeof([32]byte{}))
+
+// Memory estimates the bytes used by the map and the slots
+func (tw *TimeWheel) Memory() uint64 {
+	return uint64(tw.Len()) * timeWheelEntryBytes
+}
+
+func (tw *TimeWheel) Stop() { close(tw.stop) }
+
+// ShardedTimeWheel splits the hashes between multiple time wheels by the first
+// byte of the hash. Workers only contend for a lock if their messages land in
+// the same shard.
+type ShardedTimeWheel struct {
+	interval time.Duration
+	stop     chan struct{}
+	shards   []*TimeWheel
+}
+
+var _ Deduplicator = (*ShardedTimeWheel)(nil)
+
+// NewShardedTimeWheel creates a time wheel with the given amount of shards,
+// which should be a power of two no larger than 256
+func NewShardedTimeWheel(interval time.Duration, size uint, shards int) *ShardedTimeWheel {
+	stw := new(ShardedTimeWheel)
+	stw.interval = interval
+	stw.shards = make([]*TimeWheel, shards)
+	for i := range stw.shards {
+		stw.shards[i] = newTimeWheel(interval, size)
+	}
+	stw.stop = make(chan struct{})
+	go stw.start()
+	return stw
+}
+
+func (stw *ShardedTimeWheel) start() {
+	ticker := time.NewTicker(stw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stw.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, tw := range stw.shards {
+			tw.turn()
+		}
+	}
+}
+
+func (stw *ShardedTimeWheel) Dupe(hash [32]byte) bool {
+	return stw.shards[int(hash[0])&(len(stw.shards)-1)].Dupe(hash)
+}
+
+func (stw *ShardedTimeWheel) Len() int {
+	n := 0
+	for _, tw := range stw.shards {
+		n += tw.Len()
+	}
+	return n
+}
+
+func (stw *ShardedTimeWheel) Memory() uint64 {
+	var m uint64
+	for _, tw := range stw.shards {
+		m += tw.Memory()
+	}
+	return m
+}
+
+func (stw *ShardedTimeWheel) Stop() { close(stw.stop) }
@@ -0,0 +1,336 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Hello is sent directly to every connected peer, so nodes can tell which
+// node is behind each of their connections
+type Hello struct {
+	Time int64
+	Node string
+}
+
+// Neighbors maps the peer identifiers of the network library to node names
+type Neighbors struct {
+	mtx   sync.RWMutex
+	names map[string]string
+}
+
+func NewNeighbors() *Neighbors {
+	n := new(Neighbors)
+	n.names = make(map[string]string)
+	return n
+}
+
+func (n *Neighbors) Set(peer, node string) {
+	n.mtx.Lock()
+	n.names[peer] = node
+	n.mtx.Unlock()
+}
+
+// Resolve returns the names of the nodes behind the given peers. Peers that
+// haven't said hello yet are left out.
+func (n *Neighbors) Resolve(peers []string) []string {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+	names := make([]string, 0, len(peers))
+	for _, p := range peers {
+		if name, ok := n.names[p]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Graph is the undirected connection graph of the test network
+type Graph struct {
+	Nodes []string
+	Edges [][2]string
+	adj   map[string]map[string]bool
+}
+
+// BuildGraph combines the peers reported by the active nodes. A connection
+// counts if either side reports it.
+func BuildGraph(nodes []NodeInfo) *Graph {
+	g := &Graph{adj: make(map[string]map[string]bool)}
+	for _, ni := range nodes {
+		if ni.Active() {
+			g.adj[ni.Name] = make(map[string]bool)
+		}
+	}
+	for _, ni := range nodes {
+		if !ni.Active() {
+			continue
+		}
+		for _, p := range ni.Peers {
+			if _, ok := g.adj[p]; !ok || p == ni.Name {
+				continue
+			}
+			g.adj[ni.Name][p] = true
+			g.adj[p][ni.Name] = true
+		}
+	}
+
+	for n := range g.adj {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Strings(g.Nodes)
+	for _, a := range g.Nodes {
+		for b := range g.adj[a] {
+			if a < b {
+				g.Edges = append(g.Edges, [2]string{a, b})
+			}
+		}
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i][0] != g.Edges[j][0] {
+			return g.Edges[i][0] < g.Edges[j][0]
+		}
+		return g.Edges[i][1] < g.Edges[j][1]
+	})
+	return g
+}
+
+// DegreeCount is how many nodes have a degree
+type DegreeCount struct {
+	Degree int
+	Nodes  int
+}
+
+// GraphMetrics describes the shape of the graph. The diameter is the longest
+// shortest path within a component.
+type GraphMetrics struct {
+	Nodes          int
+	Edges          int
+	MinDegree      int
+	MaxDegree      int
+	AvgDegree      float64
+	Degrees        []DegreeCount
+	Diameter       int
+	Components     int
+	ComponentSizes []int
+	Clustering     float64
+}
+
+// distances returns the hops from the start node to every node it can reach
+func (g *Graph) distances(start string) map[string]int {
+	dist := map[string]int{start: 0}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for p := range g.adj[n] {
+			if _, ok := dist[p]; !ok {
+				dist[p] = dist[n] + 1
+				queue = append(queue, p)
+			}
+		}
+	}
+	return dist
+}
+
+func (g *Graph) Metrics() GraphMetrics {
+	gm := GraphMetrics{Nodes: len(g.Nodes), Edges: len(g.Edges)}
+	if gm.Nodes == 0 {
+		return gm
+	}
+
+	degrees := make(map[int]int)
+	gm.MinDegree = math.MaxInt32
+	clustering := 0.0
+	for _, n := range g.Nodes {
+		d := len(g.adj[n])
+		degrees[d]++
+		if d < gm.MinDegree {
+			gm.MinDegree = d
+		}
+		if d > gm.MaxDegree {
+			gm.MaxDegree = d
+		}
+		gm.AvgDegree += float64(d)
+
+		// share of the node's neighbors that are connected to each other
+		if d > 1 {
+			links := 0
+			for a := range g.adj[n] {
+				for b := range g.adj[n] {
+					if a < b && g.adj[a][b] {
+						links++
+					}
+				}
+			}
+			clustering += float64(links) / float64(d*(d-1)/2)
+		}
+	}
+	gm.AvgDegree /= float64(gm.Nodes)
+	gm.Clustering = clustering / float64(gm.Nodes)
+	for d, c := range degrees {
+		gm.Degrees = append(gm.Degrees, DegreeCount{d, c})
+	}
+	sort.Slice(gm.Degrees, func(i, j int) bool {
+		return gm.Degrees[i].Degree < gm.Degrees[j].Degree
+	})
+
+	seen := make(map[string]bool)
+	for _, n := range g.Nodes {
+		dist := g.distances(n)
+		for _, d := range dist {
+			if d > gm.Diameter {
+				gm.Diameter = d
+			}
+		}
+		if !seen[n] {
+			for m := range dist {
+				seen[m] = true
+			}
+			gm.Components++
+			gm.ComponentSizes = append(gm.ComponentSizes, len(dist))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(gm.ComponentSizes)))
+	return gm
+}
+
+// DOT exports the graph in the graphviz format
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("graph network {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&sb, "\t%q;\n", n)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "\t%q -- %q;\n", e[0], e[1])
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// GraphML exports the graph in the GraphML format
+func (g *Graph) GraphML() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	sb.WriteString(`  <graph id="network" edgedefault="undirected">` + "\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&sb, "    <node id=\"%s\"/>\n", xmlEscape(n))
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(&sb, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\"/>\n", i, xmlEscape(e[0]), xmlEscape(e[1]))
+	}
+	sb.WriteString("  </graph>\n</graphml>\n")
+	return sb.String()
+}
+
+var xmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+func xmlEscape(s string) string {
+	return xmlReplacer.Replace(s)
+}
+
+// GraphPoint is the position of a node in the drawing
+type GraphPoint struct {
+	Name string
+	X, Y float64
+}
+
+// GraphLine is an edge in the drawing
+type GraphLine struct {
+	X1, Y1, X2, Y2 float64
+}
+
+// TopologyNode puts the degree of a node next to the messages it received
+type TopologyNode struct {
+	Name     string
+	Degree   int
+	Received uint64
+	Unique   uint64
+}
+
+// DupeRate is the share of received messages that were duplicates
+func (tn TopologyNode) DupeRate() float64 {
+	if tn.Received == 0 {
+		return 0
+	}
+	return float64(tn.Received-tn.Unique) / float64(tn.Received)
+}
+
+// TopologyView is the graph laid out on a circle, along with its metrics
+type TopologyView struct {
+	Time    time.Time
+	Size    int
+	Points  []GraphPoint
+	Lines   []GraphLine
+	Metrics GraphMetrics
+	Nodes   []TopologyNode
+}
+
+// Layout places the nodes on a circle that fits into a square of the given size
+func (g *Graph) Layout(size int) TopologyView {
+	tv := TopologyView{Time: time.Now(), Size: size, Metrics: g.Metrics()}
+	center := float64(size) / 2
+	radius := center * 0.8
+	pos := make(map[string]GraphPoint)
+	for i, n := range g.Nodes {
+		angle := 2 * math.Pi * float64(i) / float64(len(g.Nodes))
+		p := GraphPoint{Name: n, X: center + radius*math.Cos(angle), Y: center + radius*math.Sin(angle)}
+		pos[n] = p
+		tv.Points = append(tv.Points, p)
+	}
+	for _, e := range g.Edges {
+		a, b := pos[e[0]], pos[e[1]]
+		tv.Lines = append(tv.Lines, GraphLine{a.X, a.Y, b.X, b.Y})
+	}
+	return tv
+}
+
+// sayHello tells every connected peer which node this is
+func (a *App) sayHello() {
+	ticker := time.NewTicker(helloInterval)
+	for range ticker.C {
+		hello := encodeControl(PeerHello, Hello{Time: time.Now().UnixNano(), Node: a.n.Name()})
+		for _, p := range a.n.Peers() {
+			a.n.DeliverMessage(p, hello)
+		}
+	}
+}
+
+func (a *App) handleHello(peer string, msg []byte) {
+	var hello Hello
+	if err := decodeControl(msg, &hello); err != nil {
+		log.Warn().Err(err).Str("peer", peer).Msg("received invalid hello")
+		return
+	}
+	a.neighbors.Set(peer, hello.Node)
+}
+
+// Topology returns the connection graph of all active nodes
+func (a *App) Topology() *Graph {
+	return BuildGraph(a.cluster.Nodes())
+}
+
+// TopologyView returns the drawing of the connection graph along with the
+// traffic of each node
+func (a *App) TopologyView(size int) TopologyView {
+	nodes := a.cluster.Nodes()
+	g := BuildGraph(nodes)
+	tv := g.Layout(size)
+	for _, ni := range nodes {
+		if !ni.Active() {
+			continue
+		}
+		tv.Nodes = append(tv.Nodes, TopologyNode{Name: ni.Name, Degree: len(g.adj[ni.Name]), Received: ni.Received, Unique: ni.Unique})
+	}
+	sort.Slice(tv.Nodes, func(i, j int) bool {
+		return tv.Nodes[i].Name < tv.Nodes[j].Name
+	})
+	return tv
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testGraph() *Graph {
+	now := time.Now()
+	return BuildGraph([]NodeInfo{
+		{Name: "a", LastSeen: now, Peers: []string{"b", "c", "d"}},
+		{Name: "b", LastSeen: now, Peers: []string{"c"}},
+		{Name: "c", LastSeen: now, Peers: []string{"a", "d"}},
+		{Name: "d", LastSeen: now},
+		{Name: "e", LastSeen: now, Peers: []string{"f", "e"}},
+		{Name: "f", LastSeen: now, Peers: []string{"e"}},
+		{Name: "g", LastSeen: now, Peers: []string{"unknown"}},
+		{Name: "h", Peers: []string{"a"}},
+	})
+}
+
+func TestBuildGraph(t *testing.T) {
+	g := testGraph()
+	if want := []string{"a", "b", "c", "d", "e", "f", "g"}; !reflect.DeepEqual(g.Nodes, want) {
+		t.Errorf("nodes = %v, want %v", g.Nodes, want)
+	}
+	want := [][2]string{{"a", "b"}, {"a", "c"}, {"a", "d"}, {"b", "c"}, {"c", "d"}, {"e", "f"}}
+	if !reflect.DeepEqual(g.Edges, want) {
+		t.Errorf("edges = %v, want %v", g.Edges, want)
+	}
+}
+
+func TestGraph_Metrics(t *testing.T) {
+	gm := testGraph().Metrics()
+	if gm.Nodes != 7 || gm.Edges != 6 {
+		t.Errorf("graph has %d nodes and %d edges, want 7 and 6", gm.Nodes, gm.Edges)
+	}
+	if gm.MinDegree != 0 || gm.MaxDegree != 3 || math.Abs(gm.AvgDegree-12.0/7) > 1e-9 {
+		t.Errorf("degrees = %d / %f / %d", gm.MinDegree, gm.AvgDegree, gm.MaxDegree)
+	}
+	if want := []DegreeCount{{0, 1}, {1, 2}, {2, 2}, {3, 2}}; !reflect.DeepEqual(gm.Degrees, want) {
+		t.Errorf("degree distribution = %v, want %v", gm.Degrees, want)
+	}
+	if gm.Diameter != 2 {
+		t.Errorf("diameter = %d, want 2", gm.Diameter)
+	}
+	if gm.Components != 3 || !reflect.DeepEqual(gm.ComponentSizes, []int{4, 2, 1}) {
+		t.Errorf("components = %d %v, want 3 [4 2 1]", gm.Components, gm.ComponentSizes)
+	}
+	if math.Abs(gm.Clustering-10.0/21) > 1e-9 {
+		t.Errorf("clustering = %f, want %f", gm.Clustering, 10.0/21)
+	}
+
+	if gm := BuildGraph(nil).Metrics(); gm.Nodes != 0 || gm.MinDegree != 0 {
+		t.Errorf("empty graph has metrics %+v", gm)
+	}
+}
+
+func TestGraph_Export(t *testing.T) {
+	g := BuildGraph([]NodeInfo{
+		{Name: "a", LastSeen: time.Now(), Peers: []string{"b&c"}},
+		{Name: "b&c", LastSeen: time.Now()},
+	})
+
+	dot := g.DOT()
+	if !strings.Contains(dot, "\"a\" -- \"b&c\";") {
+		t.Errorf("DOT is missing the edge:\n%s", dot)
+	}
+
+	graphml := g.GraphML()
+	if !strings.Contains(graphml, `<edge id="e0" source="a" target="b&amp;c"/>`) {
+		t.Errorf("GraphML is missing the edge:\n%s", graphml)
+	}
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A tracer message carries the trace id, the time it was sent on the
+// master's clock, and the type of message it imitates. The id of the node
+// that relayed it last and the hops follow, they change on every hop and are
+// not part of the hash. The rest is padding to the size of the imitated type.
+const (
+	tracerFixedLen  = 18
+	tracerHeaderLen = tracerFixedLen + 9
+)
+
+func encodeTracer(msg []byte, id uint64, sent time.Time, imitate byte) {
+	binary.BigEndian.PutUint64(msg[1:], id)
+	binary.BigEndian.PutUint64(msg[9:], uint64(sent.UnixNano()))
+	msg[17] = imitate
+}
+
+func setTracerHop(msg []byte, from uint64, hops int) {
+	binary.BigEndian.PutUint64(msg[tracerFixedLen:], from)
+	if hops > 255 {
+		hops = 255
+	}
+	msg[tracerFixedLen+8] = byte(hops)
+}
+
+func decodeTracer(msg []byte) (id uint64, sent time.Time, imitate byte, from uint64, hops int, ok bool) {
+	if len(msg) < tracerHeaderLen {
+		return
+	}
+	id = binary.BigEndian.Uint64(msg[1:])
+	sent = time.Unix(0, int64(binary.BigEndian.Uint64(msg[9:])))
+	imitate = msg[17]
+	from = binary.BigEndian.Uint64(msg[tracerFixedLen:])
+	hops = int(msg[tracerFixedLen+8])
+	return id, sent, imitate, from, hops, true
+}
+
+// TraceArrival is when and from where the last tracer reached a node. Time is
+// on the master's clock.
+type TraceArrival struct {
+	ID   uint64
+	Time int64
+	From uint64
+	Hops int
+}
+
+// Traces remembers the first arrival of the latest tracer and, on the node
+// that sent it, which tracer that was
+type Traces struct {
+	mtx     sync.Mutex
+	arrival TraceArrival
+	sent    TraceArrival
+	imitate byte
+}
+
+// Arrive records the arrival of a tracer. Returns false if it was already seen.
+func (t *Traces) Arrive(ta TraceArrival) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.arrival.ID == ta.ID {
+		return false
+	}
+	t.arrival = ta
+	return true
+}
+
+func (t *Traces) Last() TraceArrival {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.arrival
+}
+
+// TraceHop is a node in the spanning tree of a tracer
+type TraceHop struct {
+	Node    string
+	Parent  string
+	Depth   int
+	Hops    int
+	Offset  time.Duration
+	Percent float64
+}
+
+// Indent is the depth as spaces for displaying the tree
+func (th TraceHop) Indent() string {
+	return fmt.Sprintf("%*s", th.Depth*4, "")
+}
+
+// TraceView is the propagation of the last tracer sent by this node
+type TraceView struct {
+	ID       uint64
+	Type     string
+	Sent     time.Time
+	Nodes    int
+	Reached  int
+	Max      time.Duration
+	Tree     []TraceHop
+	Timeline []TraceHop
+	Missing  []string
+}
+
+// buildTrace puts the arrivals reported by the nodes together into the
+// spanning tree of the tracer, in depth first order, and the timeline of
+// arrivals
+func buildTrace(sent TraceArrival, nodes []NodeInfo) TraceView {
+	tv := TraceView{ID: sent.ID, Sent: time.Unix(0, sent.Time)}
+
+	names := make(map[uint64]string)
+	for _, ni := range nodes {
+		names[ni.ID] = ni.Name
+	}
+
+	hops := make(map[string]TraceHop)
+	children := make(map[string][]string)
+	var roots []string
+	for _, ni := range nodes {
+		if !ni.Active() {
+			continue
+		}
+		tv.Nodes++
+		if ni.Trace.ID != sent.ID {
+			tv.Missing = append(tv.Missing, ni.Name)
+			continue
+		}
+		tv.Reached++
+		th := TraceHop{Node: ni.Name, Hops: ni.Trace.Hops, Offset: time.Duration(ni.Trace.Time - sent.Time)}
+		if th.Offset > tv.Max {
+			tv.Max = th.Offset
+		}
+		if ni.Trace.From != 0 {
+			parent, ok := names[ni.Trace.From]
+			if !ok {
+				parent = fmt.Sprintf("%016x", ni.Trace.From)
+			}
+			th.Parent = parent
+			children[parent] = append(children[parent], ni.Name)
+		}
+		hops[ni.Name] = th
+	}
+
+	for name, th := range hops {
+		if _, ok := hops[th.Parent]; !ok {
+			roots = append(roots, name)
+		}
+	}
+
+	byOffset := func(list []string) {
+		sort.Slice(list, func(i, j int) bool {
+			return hops[list[i]].Offset < hops[list[j]].Offset
+		})
+	}
+	byOffset(roots)
+
+	var walk func(name string, depth int)
+	walk = func(name string, depth int) {
+		th := hops[name]
+		th.Depth = depth
+		if tv.Max > 0 {
+			th.Percent = float64(th.Offset) / float64(tv.Max) * 100
+		}
+		tv.Tree = append(tv.Tree, th)
+		kids := children[name]
+		byOffset(kids)
+		for _, kid := range kids {
+			walk(kid, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	tv.Timeline = append(tv.Timeline, tv.Tree...)
+	sort.SliceStable(tv.Timeline, func(i, j int) bool {
+		return tv.Timeline[i].Offset < tv.Timeline[j].Offset
+	})
+	sort.Strings(tv.Missing)
+	return tv
+}
+
+// TracerTypes returns the message types a tracer can imitate
+func TracerTypes() []string {
+	var types []string
+	for typ := ACK; typ < MESSAGEMAX; typ++ {
+		if hasTrailer(typ) {
+			types = append(types, MessageName(int(typ)))
+		}
+	}
+	return types
+}
+
+// SendTracer sends a tracer that imitates the size and relay policy of the
+// given message type through the network
+func (a *App) SendTracer(imitate string) error {
+	if !a.isLaunched() {
+		return errNotLaunched
+	}
+	typ, ok := MessageType(imitate)
+	if !ok || !hasTrailer(typ) {
+		return fmt.Errorf("can't imitate message type \"%s\"", imitate)
+	}
+
+	size := avgSize[typ]
+	if size < tracerHeaderLen {
+		size = tracerHeaderLen
+	}
+	msg := make([]byte, size)
+	msg[0] = Tracer
+
+	now := a.clock.Now()
+	ta := TraceArrival{ID: rand.Uint64(), Time: now.UnixNano()}
+	encodeTracer(msg, ta.ID, now, typ)
+	setTracerHop(msg, a.id, 1)
+
+	a.tracer.mtx.Lock()
+	a.tracer.sent = ta
+	a.tracer.imitate = typ
+	a.tracer.mtx.Unlock()
+	a.tracer.Arrive(ta)
+
+	for _, t := range a.relay.Targets(a.n, typ) {
+		a.n.DeliverMessage(t, msg)
+	}
+	return nil
+}
+
+// costType is the type whose processing cost applies to the message. Tracers
+// pay the cost of the type they imitate, so they don't overtake it.
+func costType(msg []byte) byte {
+	if msg[0] == Tracer && len(msg) >= tracerHeaderLen {
+		return msg[17]
+	}
+	return msg[0]
+}
+
+// handleTracer records the first arrival of a tracer and passes it on
+// like the message it imitates
+func (a *App) handleTracer(peer string, msg []byte) bool {
+	id, _, imitate, from, hops, ok := decodeTracer(msg)
+	if !ok || imitate >= MESSAGEMAX {
+		return false
+	}
+	if !a.tracer.Arrive(TraceArrival{ID: id, Time: a.clock.Now().UnixNano(), From: from, Hops: hops}) {
+		return false
+	}
+
+	targets := a.relay.Targets(a.n, imitate)
+	if len(targets) == 0 {
+		return false
+	}
+	next := make([]byte, len(msg))
+	copy(next, msg)
+	setTracerHop(next, a.id, hops+1)
+	for _, t := range targets {
+		a.n.DeliverMessage(t, next)
+	}
+	return true
+}
+
+// Trace returns the propagation of the last tracer this node sent
+func (a *App) Trace() TraceView {
+	a.tracer.mtx.Lock()
+	sent, imitate := a.tracer.sent, a.tracer.imitate
+	a.tracer.mtx.Unlock()
+	if sent.ID == 0 {
+		return TraceView{}
+	}
+	tv := buildTrace(sent, a.cluster.Nodes())
+	tv.Type = MessageName(int(imitate))
+	return tv
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_buildTrace(t *testing.T) {
+	now := time.Now()
+	sent := TraceArrival{ID: 7, Time: now.UnixNano()}
+	ms := int64(time.Millisecond)
+	nodes := []NodeInfo{
+		{Name: "host", ID: 1, LastSeen: now, Trace: sent},
+		{Name: "b", ID: 2, LastSeen: now, Trace: TraceArrival{ID: 7, Time: sent.Time + 30*ms, From: 3, Hops: 2}},
+		{Name: "c", ID: 3, LastSeen: now, Trace: TraceArrival{ID: 7, Time: sent.Time + 10*ms, From: 1, Hops: 1}},
+		{Name: "d", ID: 4, LastSeen: now, Trace: TraceArrival{ID: 7, Time: sent.Time + 20*ms, From: 1, Hops: 1}},
+		{Name: "e", ID: 5, LastSeen: now, Trace: TraceArrival{ID: 6}},
+	}
+
+	tv := buildTrace(sent, nodes)
+	if tv.Nodes != 5 || tv.Reached != 4 || tv.Max != 30*time.Millisecond {
+		t.Errorf("unexpected summary %+v", tv)
+	}
+	if len(tv.Missing) != 1 || tv.Missing[0] != "e" {
+		t.Errorf("missing = %v, want [e]", tv.Missing)
+	}
+
+	want := []struct {
+		node  string
+		depth int
+	}{{"host", 0}, {"c", 1}, {"b", 2}, {"d", 1}}
+	if len(tv.Tree) != len(want) {
+		t.Fatalf("tree has %d nodes, want %d", len(tv.Tree), len(want))
+	}
+	for i, w := range want {
+		if tv.Tree[i].Node != w.node || tv.Tree[i].Depth != w.depth {
+			t.Errorf("tree[%d] = %s at %d, want %s at %d", i, tv.Tree[i].Node, tv.Tree[i].Depth, w.node, w.depth)
+		}
+	}
+	if tv.Timeline[3].Node != "b" || tv.Timeline[3].Percent != 100 {
+		t.Errorf("unexpected last arrival %+v", tv.Timeline[3])
+	}
+}
+
+func Test_costType(t *testing.T) {
+	tracer := make([]byte, tracerHeaderLen)
+	tracer[0] = Tracer
+	encodeTracer(tracer, 1, time.Now(), EOM)
+	if typ := costType(tracer); typ != EOM {
+		t.Errorf("costType(tracer) = %d, want EOM", typ)
+	}
+	if typ := costType([]byte{Tracer}); typ != Tracer {
+		t.Errorf("costType(short tracer) = %d, want Tracer", typ)
+	}
+	if typ := costType([]byte{ACK, 1, 2}); typ != ACK {
+		t.Errorf("costType(ACK) = %d, want ACK", typ)
+	}
+}
+
+func TestApp_SendTracerBeforeLaunch(t *testing.T) {
+	if err := NewApp().SendTracer("ACK"); err != errNotLaunched {
+		t.Errorf("SendTracer() before launch = %v, want %v", err, errNotLaunched)
+	}
+}
@@ -18,6 +18,14 @@ const (
 	DBStateRequest
 	DBStateReply
 	StartRecording
+	NodeAnnounce
+	LoadControl
+	RoleControl
+	ClockControl
+	CostControl
+	RelayControl
+	Tracer
+	PeerHello
 	MESSAGEMAX
 )
 
@@ -49,10 +57,36 @@ func MessageName(b int) string {
 		return "DBStateReply"
 	case StartRecording:
 		return "StartRecording"
+	case NodeAnnounce:
+		return "NodeAnnounce"
+	case LoadControl:
+		return "LoadControl"
+	case RoleControl:
+		return "RoleControl"
+	case ClockControl:
+		return "ClockControl"
+	case CostControl:
+		return "CostControl"
+	case RelayControl:
+		return "RelayControl"
+	case Tracer:
+		return "Tracer"
+	case PeerHello:
+		return "PeerHello"
 	}
 	return "UNKNOWN"
 }
 
+// MessageType is the reverse of MessageName
+func MessageType(name string) (byte, bool) {
+	for b := ACK; b < MESSAGEMAX; b++ {
+		if MessageName(int(b)) == name {
+			return b, true
+		}
+	}
+	return Invalid, false
+}
+
 // Average Byte-Size of messages
 // calculated from 68 hours of mainnet traffic
 var avgSize = map[byte]int{
@@ -74,8 +108,7 @@ var avgSize = map[byte]int{
 var minuteDuration = time.Minute
 var minutesPerBlock = 10
 
-var dbstateLikelihood = 0.7621359223300971    // 76.2% likelihood for dbstate request after block duration, 314 / 412
-var missingmsgLikelihood = 0.7008092142418409 // 70.1% likelihood of missingmsg for every *NEW* ACK, 170003 / 242581
+var dbstateLikelihood = 0.7621359223300971 // 76.2% likelihood for dbstate request after block duration, 314 / 412
 
 // makeup of transactions to chains to entries
 var entryPercent = map[byte]float64{
@@ -85,3 +118,94 @@ var entryPercent = map[byte]float64{
 }
 
 var workers int = 4
+
+// nodes announce themselves to the cluster in this interval and are
+// considered gone after not being heard from for nodeTimeout
+var announceInterval = time.Second * 5
+var nodeTimeout = time.Second * 20
+
+// capacity of the loopback network used for benchmarking, same as the p2p channels
+var loopbackCapacity = 10000
+
+// size of the slabs the pooled generator carves messages from
+var slabSize = 64 * 1024
+
+// number of clock sync messages used to calculate the offset to the master
+var clockSamples = 5
+
+// the load generator ramps up by this many eps every rampInterval
+var rampStep = 500
+var rampInterval = time.Second * 30
+
+// gaps in the ACKs are given repairDelay to fill on their own before a
+// MissingMsg is sent. Unanswered requests are repeated after repairTimeout,
+// up to repairAttempts times. The last repairStore ACKs are kept to answer
+// requests with.
+var repairInterval = time.Millisecond * 100
+var repairDelay = time.Second
+var repairTimeout = time.Second * 2
+var repairAttempts = 3
+var repairStore = 50000
+
+// DBStateRequests without a reply are sent to another peer after
+// dbstateTimeout, up to dbstateAttempts times
+var dbstateInterval = time.Millisecond * 250
+var dbstateTimeout = time.Second * 5
+var dbstateAttempts = 3
+
+// entries that don't arrive in full within entryTimeout count as incomplete
+var entryTimeout = time.Second * 30
+
+// nodes keep the message digests of the last digestHistory minutes
+var digestHistory = 10
+
+// pathSample of the messages created record their path, the last pathHistory
+// paths are kept
+var pathSample = 0.01
+var pathHistory = 20
+
+// how often to check for the first peer after launch
+var firstPeerPoll = time.Millisecond * 50
+
+// nodes tell their peers who they are every helloInterval
+var helloInterval = time.Second * 10
+
+// a fanout sweep samples the cluster's bandwidth every sweepSampleInterval
+var sweepSampleInterval = time.Second * 5
+
+// load generator sends messages every tick
+var generatorTick = time.Millisecond * 10
+
+// on/off arrival: bursts of 5s followed by 10s of silence
+var onoffOn = time.Second * 5
+var onoffOff = time.Second * 10
+
+// surge arrival: the first 6s of a minute receive 4x the average rate
+var surgeWindow = time.Second * 6
+var surgeFactor = 4.0
+
+// messages are remembered for replayInterval * replaySize
+var replayInterval = time.Minute
+var replaySize uint = 10
+
+// maximum number of hashes the lru dedup holds
+var lruSize = 1 << 20
+
+// the bloom dedup uses 2^24 bits (2 MiB) per interval and 4 hash functions,
+// which keeps each filter below 1% false positives for up to 1.6 million
+// messages per interval
+var bloomBits uint64 = 1 << 24
+var bloomHashes = 4
+
+// the adaptive fanout is adjusted every interval. it doubles if more than
+// adaptiveLoss of the ACKs, entries, or minutes were lost, goes down by one
+// if more than adaptiveDupeHigh of received messages were duplicates, and up
+// by one if fewer than adaptiveDupeLow were
+var adaptiveInterval = time.Second * 10
+var adaptiveLoss = 0.01
+var adaptiveDupeHigh = 0.8
+var adaptiveDupeLow = 0.5
+var adaptiveHistory = 360
+
+// number of shards of the sharded time wheel dedup
+var dedupShards = 64
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// worker reads messages from the network and processes them. The time spent
+// waiting for a message counts as idle, processing it as busy.
+type worker struct {
+	id   int
+	quit chan bool
+
+	messages uint64
+	busy     int64
+	idle     int64
+
+	// last values seen by calculateStats
+	lastMessages uint64
+	lastBusy     int64
+	lastIdle     int64
+}
+
+// WorkerStats is the activity of a single worker in the last second
+type WorkerStats struct {
+	ID          int
+	MPS         uint64
+	Busy        time.Duration
+	Idle        time.Duration
+	AvgTime     time.Duration
+	Utilization float64
+}
+
+func (a *App) runWorker(w *worker) {
+	for {
+		select {
+		case <-w.quit:
+			return
+		default:
+		}
+
+		start := time.Now()
+		peer, msg := a.n.ReadMessage()
+		read := time.Now()
+		a.process(peer, msg)
+
+		atomic.AddInt64(&w.idle, int64(read.Sub(start)))
+		atomic.AddInt64(&w.busy, int64(time.Since(read)))
+		atomic.AddUint64(&w.messages, 1)
+	}
+}
+
+// SetWorkers changes the number of workers processing messages. Workers that
+// are removed stop after they finish their current message. Before the
+// workers are started, only the number is stored.
+func (a *App) SetWorkers(n int) error {
+	if n < 1 {
+		return fmt.Errorf("need at least one worker")
+	}
+
+	a.workerMtx.Lock()
+	defer a.workerMtx.Unlock()
+	a.workerCount = n
+	if a.workersStarted {
+		a.resizePool()
+	}
+	return nil
+}
+
+// startWorkers starts the workers once the network is set
+func (a *App) startWorkers() {
+	a.workerMtx.Lock()
+	defer a.workerMtx.Unlock()
+	a.workersStarted = true
+	a.resizePool()
+}
+
+// resizePool starts or stops workers until there are workerCount. Expects the
+// lock to be held.
+func (a *App) resizePool() {
+	n := a.workerCount
+	for len(a.pool) < n {
+		w := &worker{id: a.nextWorker, quit: make(chan bool)}
+		a.nextWorker++
+		a.pool = append(a.pool, w)
+		go a.runWorker(w)
+	}
+	for len(a.pool) > n {
+		last := a.pool[len(a.pool)-1]
+		close(last.quit)
+		a.pool = a.pool[:len(a.pool)-1]
+	}
+}
+
+// Workers returns the number of workers
+func (a *App) Workers() int {
+	a.workerMtx.Lock()
+	defer a.workerMtx.Unlock()
+	return a.workerCount
+}
+
+// workerStats calculates the activity of each worker since the last call
+func (a *App) workerStats() []WorkerStats {
+	a.workerMtx.Lock()
+	defer a.workerMtx.Unlock()
+
+	stats := make([]WorkerStats, 0, len(a.pool))
+	for _, w := range a.pool {
+		messages := atomic.LoadUint64(&w.messages)
+		busy := atomic.LoadInt64(&w.busy)
+		idle := atomic.LoadInt64(&w.idle)
+
+		ws := WorkerStats{
+			ID:   w.id,
+			MPS:  messages - w.lastMessages,
+			Busy: time.Duration(busy - w.lastBusy),
+			Idle: time.Duration(idle - w.lastIdle),
+		}
+		if ws.MPS > 0 {
+			ws.AvgTime = ws.Busy / time.Duration(ws.MPS)
+		}
+		if total := ws.Busy + ws.Idle; total > 0 {
+			ws.Utilization = float64(ws.Busy) / float64(total)
+		}
+		stats = append(stats, ws)
+
+		w.lastMessages, w.lastBusy, w.lastIdle = messages, busy, idle
+	}
+	return stats
+}
+
+// Percent is the utilization in percent
+func (ws WorkerStats) Percent() float64 {
+	return ws.Utilization * 100
+}
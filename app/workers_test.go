@@ -0,0 +1,18 @@
+package app
+
+import "testing"
+
+func TestApp_SetWorkersBeforeLaunch(t *testing.T) {
+	a := NewApp()
+	if err := a.SetWorkers(3); err != nil {
+		t.Fatal(err)
+	}
+	if a.Workers() != 3 || len(a.pool) != 0 {
+		t.Errorf("workers = %d with %d running, want 3 with none running", a.Workers(), len(a.pool))
+	}
+
+	a, _ = newLoopbackApp(false)
+	if len(a.pool) != workers {
+		t.Errorf("%d workers running after start, want %d", len(a.pool), workers)
+	}
+}
@@ -19,19 +19,32 @@ import (
 )
 
 type ControlPanel struct {
-	bcast    int
-	host     bool
-	port     string
-	n        network.Network
-	cancel   func()
-	template *template.Template
-	enabled  bool
-	eps      int
-	audits   int
-	feds     int
-	load     bool
-	enabler  sync.Once
-	app      *app.App
+	bcast      int
+	host       bool
+	port       string
+	n          network.Network
+	cancel     func()
+	template   *template.Template
+	enabled    bool
+	eps        int
+	audits     int
+	feds       int
+	arrival    string
+	distribute string
+	shares     string
+	roleMode   string
+	roles      string
+	sweep      string
+	settle     string
+	measure    string
+	tracer     string
+	seedMtx    sync.Mutex
+	seed       *SeedServer
+	p2pConfig  string
+	advertise  string
+	load       bool
+	enabler    sync.Once
+	app        *app.App
 }
 
 func NewControlPanel(port string, host bool, bcast int) (*ControlPanel, error) {
@@ -45,6 +58,13 @@ func NewControlPanel(port string, host bool, bcast int) (*ControlPanel, error) {
 	cp.host = host
 	cp.audits = 26
 	cp.feds = 27
+	cp.arrival = "constant"
+	cp.distribute = "local"
+	cp.roleMode = "host"
+	cp.sweep = "2,4,8,16,32"
+	cp.settle = "30s"
+	cp.measure = "2m"
+	cp.tracer = "EOM"
 	cp.port = port
 	cp.template = template
 	cp.app = app.NewApp()
@@ -84,7 +104,27 @@ func (cp *ControlPanel) createNetwork(s settings) error {
 		log.Fatal().Msg("protocol verify fail")
 	}
 
-	cancel, err := n.Init(s.Name, s.P2PPort, s.Seed, s.Broadcast)
+	seed, err := seedURL(s.Seed, s.Name, s.P2PPort)
+	if err != nil {
+		return err
+	}
+
+	special, err := network.ParseSpecialPeers(s.Special)
+	if err != nil {
+		return err
+	}
+	if err := n.SetSpecialPeers(special, s.Exclusive); err != nil {
+		return err
+	}
+	// the form keeps the p2p2 configuration filled in, the old library
+	// has none to apply
+	if s.Protocol != "p2p1-v9" {
+		if err := n.Configure(s.P2PConfig); err != nil {
+			return err
+		}
+	}
+
+	cancel, err := n.Init(s.Name, s.P2PPort, seed, s.Broadcast)
 	if err != nil {
 		return err
 	}
@@ -97,11 +137,34 @@ func (cp *ControlPanel) createNetwork(s settings) error {
 func (cp *ControlPanel) startSeed(s settings) {
 	fmt.Println(s.SeedStart, s.SeedPort, s.SeedContent)
 	if s.SeedStart == "1" {
-		srv := NewSeedServer(s.SeedPort, s.SeedContent)
-		go srv.Run()
+		srv, err := NewSeedServer(s.SeedPort, s.SeedContent, s.SeedTopology, s.SeedParams)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to start seed server")
+			return
+		}
+		srv.list.Shuffle = s.SeedShuffle == "1"
+		srv.list.Subset, _ = strconv.Atoi(s.SeedSubset)
+		srv.list.Expiry, _ = time.ParseDuration(s.SeedExpiry)
+		srv.list.Advertise = cp.advertise
+		ln, err := srv.Listen()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to start seed server")
+			return
+		}
+		cp.seedMtx.Lock()
+		cp.seed = srv
+		cp.seedMtx.Unlock()
+		go srv.Serve(ln)
 	}
 }
 
+// seedServer returns the seed server started by the enable form, if any
+func (cp *ControlPanel) seedServer() *SeedServer {
+	cp.seedMtx.Lock()
+	defer cp.seedMtx.Unlock()
+	return cp.seed
+}
+
 func (cp *ControlPanel) Launch() error {
 	mux := http.NewServeMux()
 
@@ -110,6 +173,20 @@ func (cp *ControlPanel) Launch() error {
 	mux.HandleFunc("/peers", cp.peers)
 	mux.HandleFunc("/report", cp.report)
 	mux.HandleFunc("/eps", cp.epsf)
+	mux.HandleFunc("/nodes", cp.nodes)
+	mux.HandleFunc("/roles", cp.rolesf)
+	mux.HandleFunc("/workers", cp.workersf)
+	mux.HandleFunc("/cost", cp.costf)
+	mux.HandleFunc("/relay", cp.relayf)
+	mux.HandleFunc("/sweep", cp.sweepf)
+	mux.HandleFunc("/sweepreport", cp.sweepReport)
+	mux.HandleFunc("/consistency", cp.consistency)
+	mux.HandleFunc("/trace", cp.tracef)
+	mux.HandleFunc("/tracereport", cp.traceReport)
+	mux.HandleFunc("/topology", cp.topology)
+	mux.HandleFunc("/topology.dot", cp.topologyDOT)
+	mux.HandleFunc("/topology.graphml", cp.topologyGraphML)
+	mux.HandleFunc("/seedfault", cp.seedFault)
 
 	return http.ListenAndServe(fmt.Sprintf(":%s", cp.port), mux)
 }
@@ -117,8 +194,11 @@ func (cp *ControlPanel) Launch() error {
 var validProtocols = []string{"p2p1-v9", "p2p2-v9", "p2p2-v10", "p2p2-v11"}
 
 type settings struct {
-	Name, P2PPort, Protocol, Seed, SeedStart, SeedPort, SeedContent string
-	Broadcast                                                       int
+	Name, P2PPort, Protocol, Seed, SeedRegister, SeedStart, SeedPort, Dedup string
+	SeedContent                                                             string
+	SeedTopology, SeedParams, SeedShuffle, SeedSubset, SeedExpiry           string
+	Special, Exclusive, P2PConfig                                           string
+	Broadcast                                                               int
 }
 
 func (cp *ControlPanel) verify(s settings) error {
@@ -145,12 +225,47 @@ func (cp *ControlPanel) verify(s settings) error {
 		return fmt.Errorf("no seed server specified")
 	}
 
+	found = s.Dedup == ""
+	for _, name := range app.DedupNames() {
+		if s.Dedup == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown dedup strategy \"%s\"", s.Dedup)
+	}
+
+	special, err := network.ParseSpecialPeers(s.Special)
+	if err != nil {
+		return err
+	}
+	found = false
+	for _, mode := range network.ExclusiveModes() {
+		if s.Exclusive == mode {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid exclusive mode \"%s\"", s.Exclusive)
+	}
+	if s.Exclusive != network.ExclusiveOff && len(special) == 0 {
+		return fmt.Errorf("exclusive mode needs special peers")
+	}
+
 	if s.SeedStart == "1" {
 		if _, err := strconv.Atoi(s.SeedPort); err != nil {
 			return err
 		}
-		if len(s.SeedContent) == 0 {
-			return fmt.Errorf("no seed server specified")
+		if _, err := app.NewSeedList(app.ParseSeeds(s.SeedContent), s.SeedTopology, s.SeedParams); err != nil {
+			return err
+		}
+		if subset, err := strconv.Atoi(s.SeedSubset); err != nil || subset < 0 {
+			return fmt.Errorf("invalid seed subset \"%s\"", s.SeedSubset)
+		}
+		if _, err := time.ParseDuration(s.SeedExpiry); err != nil {
+			return err
 		}
 	}
 
@@ -172,14 +287,51 @@ func (cp *ControlPanel) index(rw http.ResponseWriter, r *http.Request) {
 	if !cp.host && !cp.enabled {
 		p = fmt.Sprintf("%d", 10001+rand.Intn(1024))
 	}
+	relay, relayParams := cp.app.Relay()
+	netConfig := ""
+	if cp.n != nil {
+		netConfig = cp.n.Config()
+	}
+	seed := cp.seedServer()
+	seedFault := &app.SeedFault{Mode: "none"}
+	if seed != nil {
+		seedFault = seed.Fault()
+	}
 	cp.exec("index.html", rw, map[string]interface{}{
-		"p2pport": p,
-		"host":    cp.host,
-		"enabled": cp.enabled,
-		"load":    cp.load,
-		"eps":     cp.eps,
-		"feds":    cp.feds,
-		"audits":  cp.audits,
+		"p2pport":       p,
+		"host":          cp.host,
+		"enabled":       cp.enabled,
+		"load":          cp.load,
+		"eps":           cp.eps,
+		"feds":          cp.feds,
+		"audits":        cp.audits,
+		"arrival":       cp.arrival,
+		"arrivals":      app.ArrivalNames(),
+		"distribute":    cp.distribute,
+		"distributions": app.DistributionNames(),
+		"shares":        cp.shares,
+		"roleMode":      cp.roleMode,
+		"roleModes":     app.RoleModeNames(),
+		"roles":         cp.roles,
+		"dedups":        app.DedupNames(),
+		"topologies":    app.TopologyNames(),
+		"exclusives":    network.ExclusiveModes(),
+		"p2pConfig":     cp.p2pConfig,
+		"p2pDefaults":   network.DefaultV10Config(),
+		"netConfig":     netConfig,
+		"workers":       cp.app.Workers(),
+		"cost":          cp.app.Cost(),
+		"relay":         relay,
+		"relayParams":   relayParams,
+		"relays":        app.RelayNames(),
+		"sweep":         cp.sweep,
+		"settle":        cp.settle,
+		"measure":       cp.measure,
+		"tracer":        cp.tracer,
+		"tracers":       app.TracerTypes(),
+		"seedServer":    seed != nil,
+		"seedFault":     seedFault,
+		"seedFaults":    app.SeedFaultNames(),
 	})
 }
 
@@ -206,11 +358,192 @@ func (cp *ControlPanel) epsf(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cp.app.ApplyLoad(enable, eps, feds, audits)
+	arrival := r.FormValue("arrival")
+	if _, err := app.NewArrival(arrival); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	distribute := r.FormValue("distribute")
+	shares := r.FormValue("shares")
+	if err := cp.app.DistributeLoad(enable, eps, feds, audits, arrival, distribute, shares); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
 	cp.load = enable
 	cp.eps = eps
 	cp.feds = feds
 	cp.audits = audits
+	cp.arrival = arrival
+	cp.distribute = distribute
+	cp.shares = shares
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) rolesf(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	feds, err := strconv.Atoi(r.FormValue("feds"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	audits, err := strconv.Atoi(r.FormValue("audits"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	mode := r.FormValue("mode")
+	roles := r.FormValue("roles")
+	if err := cp.app.AssignRoles(mode, feds, audits, roles); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	cp.feds = feds
+	cp.audits = audits
+	cp.roleMode = mode
+	cp.roles = roles
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) workersf(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	workers, err := strconv.Atoi(r.FormValue("workers"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	if err := cp.app.SetWorkers(workers); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) costf(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := cp.app.SetCost(r.FormValue("cost"), r.FormValue("all") == "1"); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) relayf(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := cp.app.SetRelay(r.FormValue("policy"), r.FormValue("params"), r.FormValue("all") == "1"); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) sweepf(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("stop") == "1" {
+		cp.app.StopSweep()
+		http.Redirect(rw, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	fanouts, err := app.ParseFanouts(r.FormValue("fanouts"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	settle, err := time.ParseDuration(r.FormValue("settle"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	measure, err := time.ParseDuration(r.FormValue("measure"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	// run the load configured in the load generator, starting it if it isn't running
+	cfg := app.SweepConfig{
+		Fanouts: fanouts,
+		Settle:  settle,
+		Measure: measure,
+		Start:   !cp.load,
+		EPS:     cp.eps,
+		Feds:    cp.feds,
+		Audits:  cp.audits,
+		Arrival: cp.arrival,
+		Mode:    cp.distribute,
+		Spec:    cp.shares,
+	}
+	if err := cp.app.StartSweep(cfg); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+
+	cp.sweep = r.FormValue("fanouts")
+	cp.settle = r.FormValue("settle")
+	cp.measure = r.FormValue("measure")
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) tracef(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := cp.app.SendTracer(r.FormValue("type")); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	cp.tracer = r.FormValue("type")
+
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (cp *ControlPanel) seedFault(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	seed := cp.seedServer()
+	if seed == nil {
+		http.Error(rw, "no seed server running", http.StatusNotAcceptable)
+		return
+	}
+	if err := seed.SetFault(r.FormValue("mode"), r.FormValue("params")); err != nil {
+		http.Error(rw, err.Error(), http.StatusNotAcceptable)
+		return
+	}
 
 	http.Redirect(rw, r, "/", http.StatusSeeOther)
 }
@@ -222,24 +555,56 @@ func (cp *ControlPanel) enable(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	set := settings{
-		Name:        r.FormValue("name"),
-		P2PPort:     r.FormValue("p2pport"),
-		Protocol:    r.FormValue("protocol"),
-		Seed:        r.FormValue("seed"),
-		SeedStart:   r.FormValue("seed-start"),
-		SeedPort:    r.FormValue("seed-port"),
-		SeedContent: r.FormValue("seed-content"),
-		Broadcast:   cp.bcast,
+		Name:         r.FormValue("name"),
+		P2PPort:      r.FormValue("p2pport"),
+		Protocol:     r.FormValue("protocol"),
+		Seed:         r.FormValue("seed"),
+		SeedRegister: r.FormValue("seed-register"),
+		SeedStart:    r.FormValue("seed-start"),
+		SeedPort:     r.FormValue("seed-port"),
+		SeedContent:  r.FormValue("seed-content"),
+		SeedTopology: r.FormValue("seed-topology"),
+		SeedParams:   r.FormValue("seed-params"),
+		SeedShuffle:  r.FormValue("seed-shuffle"),
+		SeedSubset:   r.FormValue("seed-subset"),
+		SeedExpiry:   r.FormValue("seed-expiry"),
+		Special:      r.FormValue("special"),
+		Exclusive:    r.FormValue("exclusive"),
+		P2PConfig:    r.FormValue("p2pconfig"),
+		Dedup:        r.FormValue("dedup"),
+		Broadcast:    cp.bcast,
+	}
+
+	var register string
+	if set.SeedRegister == "1" {
+		var err error
+		if register, err = registerURL(set.Seed, set.Name, set.P2PPort, cp.advertise); err != nil {
+			http.Error(rw, err.Error(), http.StatusNotAcceptable)
+			return
+		}
 	}
 
 	if err := cp.createNetwork(set); err != nil {
 		http.Error(rw, err.Error(), http.StatusNotAcceptable)
 		return
 	}
+	cp.p2pConfig = set.P2PConfig
 	cp.startSeed(set)
 
 	cp.enabler.Do(func() {
+		if err := cp.app.SetDedup(set.Dedup); err != nil {
+			log.Error().Err(err).Msg("unable to set dedup strategy")
+		}
 		cp.enabled = true
+		cp.app.SetClockMaster(cp.host)
+		if register != "" {
+			// register before the network fetches the seeds, so this node
+			// is already in the list it gets
+			if err := registerSeed(&http.Client{Timeout: seedHeartbeat}, register); err != nil {
+				log.Warn().Err(err).Str("url", register).Msg("unable to register with seed server")
+			}
+			go heartbeatSeed(register)
+		}
 		go cp.Start()
 		go cp.app.Launch(cp.n)
 	})
@@ -258,3 +623,35 @@ func (cp *ControlPanel) peers(rw http.ResponseWriter, r *http.Request) {
 func (cp *ControlPanel) report(rw http.ResponseWriter, r *http.Request) {
 	cp.exec("report.html", rw, cp.app.Stats())
 }
+
+func (cp *ControlPanel) nodes(rw http.ResponseWriter, r *http.Request) {
+	cp.exec("nodes.html", rw, cp.app.Nodes())
+}
+
+func (cp *ControlPanel) sweepReport(rw http.ResponseWriter, r *http.Request) {
+	cp.exec("sweep.html", rw, cp.app.SweepStats())
+}
+
+func (cp *ControlPanel) consistency(rw http.ResponseWriter, r *http.Request) {
+	cp.exec("consistency.html", rw, cp.app.Consistency())
+}
+
+func (cp *ControlPanel) traceReport(rw http.ResponseWriter, r *http.Request) {
+	cp.exec("trace.html", rw, cp.app.Trace())
+}
+
+func (cp *ControlPanel) topology(rw http.ResponseWriter, r *http.Request) {
+	cp.exec("topology.html", rw, cp.app.TopologyView(400))
+}
+
+func (cp *ControlPanel) topologyDOT(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	rw.Header().Set("Content-Disposition", `attachment; filename="topology.dot"`)
+	fmt.Fprint(rw, cp.app.Topology().DOT())
+}
+
+func (cp *ControlPanel) topologyGraphML(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	rw.Header().Set("Content-Disposition", `attachment; filename="topology.graphml"`)
+	fmt.Fprint(rw, cp.app.Topology().GraphML())
+}
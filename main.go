@@ -2,8 +2,13 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/WhoSoup/factom-p2p-tps/app"
+	"github.com/WhoSoup/factom-p2p-tps/network"
+
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -11,6 +16,11 @@ import (
 func main() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "15:04:05", NoColor: true})
 
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		runSeedCommand(os.Args[2:])
+		return
+	}
+
 	//	var seedServer, seedPort string
 
 	//p2p1 := flag.Bool("p2p1", false, "enable to use the original factom p2p codebase. limited to v9")
@@ -19,16 +29,37 @@ func main() {
 	port := flag.String("port", "7999", "the port for the control panel")
 	host := flag.Bool("host", false, "enable to expose the host functionality")
 	bcast := flag.Int("broadcast", 16, "number of peers to send broadcasts to")
+	pooled := flag.Bool("pooled", false, "enable to use the low allocation message generator")
+	bench := flag.Duration("bench", 0, "if set, run the loopback benchmark for this long and exit")
+	advertise := flag.String("advertise", "", "the host other machines reach this one at, replaces loopback addresses in seed registrations")
+	p2pconfig := flag.String("p2pconfig", "", "a file with p2p2 settings as Field=value lines to fill the enable form with")
 	//p2pport := flag.String("p2pport", "8111", "the port to use for this client (if running multiple nodes on one machine)")
 	//seed := flag.String("seed", "", "the url of the seed server")
 	///	flag.StringVar(&seedServer, "seedserver", "", "if this is set, a seed server is started containing the addresses listed (comma separated)")
 	//	flag.StringVar(&seedPort, "seedport", "8112", "the port of the seed server")
 	flag.Parse()
 
+	if *bench > 0 {
+		fmt.Println(app.RunBenchmark(*bench, *pooled))
+		return
+	}
+
 	cp, err := NewControlPanel(*port, *host, *bcast)
 	if err != nil {
 		log.Fatal().Err(err).Msg("unable to start control panel")
 	}
+	cp.app.SetPooledGenerator(*pooled)
+	cp.advertise = *advertise
+	if *p2pconfig != "" {
+		data, err := ioutil.ReadFile(*p2pconfig)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to read p2p configuration")
+		}
+		if err := network.NewV10(10).Configure(string(data)); err != nil {
+			log.Fatal().Err(err).Msg("invalid p2p configuration")
+		}
+		cp.p2pConfig = string(data)
+	}
 	log.Info().Msgf("Control panel started: http://localhost:%s/", *port)
 	log.Fatal().Err(cp.Launch()).Msg("control panel shut down")
 }
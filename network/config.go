@@ -0,0 +1,141 @@
+package network
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	p2p "github.com/WhoSoup/factom-p2p"
+)
+
+// fields of the p2p2 configuration that are set by the app or the enable
+// form and can't be overridden
+var fixedV10Fields = map[string]bool{
+	"Network":         true,
+	"NodeID":          true,
+	"NodeName":        true,
+	"SeedURL":         true,
+	"ListenPort":      true,
+	"Fanout":          true,
+	"Special":         true,
+	"ProtocolVersion": true,
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FormatConfig lists the fields of a configuration struct as "Field=value"
+// lines, in the order they are declared
+func FormatConfig(config interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	var sb strings.Builder
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface:
+			continue
+		}
+		fmt.Fprintf(&sb, "%s=%v\n", f.Name, v.Field(i).Interface())
+	}
+	return sb.String()
+}
+
+// setField parses the value into a field of type string, bool, integer, or
+// time.Duration
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("negative duration")
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// ApplyV10Config overrides the fields of a p2p2 configuration with the
+// "Field=value" lines of overrides. Empty lines and lines starting with "#"
+// are ignored.
+func ApplyV10Config(config *p2p.Configuration, overrides string) error {
+	v := reflect.ValueOf(config).Elem()
+	for _, line := range strings.Split(overrides, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid line \"%s\", expected Field=value", line)
+		}
+		name, value := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+		if fixedV10Fields[name] {
+			return fmt.Errorf("%s is set by the enable form", name)
+		}
+		field := v.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			return fmt.Errorf("unknown configuration field \"%s\"", name)
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return validateV10Config(config)
+}
+
+// validateV10Config checks that the limits of a p2p2 configuration fit together
+func validateV10Config(c *p2p.Configuration) error {
+	if c.ProtocolVersionMinimum > c.ProtocolVersion {
+		return fmt.Errorf("ProtocolVersionMinimum %d is above ProtocolVersion %d", c.ProtocolVersionMinimum, c.ProtocolVersion)
+	}
+	if c.TargetPeers > c.MaxPeers {
+		return fmt.Errorf("TargetPeers %d is above MaxPeers %d", c.TargetPeers, c.MaxPeers)
+	}
+	if c.DropTo > c.MaxPeers {
+		return fmt.Errorf("DropTo %d is above MaxPeers %d", c.DropTo, c.MaxPeers)
+	}
+	if c.ChannelCapacity == 0 {
+		return fmt.Errorf("ChannelCapacity has to be positive")
+	}
+	return nil
+}
+
+// DefaultV10Config lists the p2p2 configuration used by default
+func DefaultV10Config() string {
+	return FormatConfig(NewV10(10).(*V10).config)
+}
@@ -0,0 +1,44 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	p2p "github.com/WhoSoup/factom-p2p"
+)
+
+func TestApplyV10Config(t *testing.T) {
+	c := p2p.Configuration{ProtocolVersion: 10, ChannelCapacity: 100, MaxPeers: 36}
+	err := ApplyV10Config(&c, "# comment\nPingInterval = 15s\n\nTargetPeers=20\nEnablePrometheus=true\nBindIP=127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PingInterval != 15*time.Second || c.TargetPeers != 20 || !c.EnablePrometheus || c.BindIP != "127.0.0.1" {
+		t.Errorf("configuration not applied: %+v", c)
+	}
+
+	for _, bad := range []string{
+		"Nonexistent=1",
+		"TargetPeers",
+		"TargetPeers=-1",
+		"PingInterval=5",
+		"PingInterval=-5s",
+		"SeedURL=http://localhost",
+		"TargetPeers=40",
+		"ProtocolVersionMinimum=11",
+		"ChannelCapacity=0",
+	} {
+		c := p2p.Configuration{ProtocolVersion: 10, ChannelCapacity: 100, MaxPeers: 36}
+		if err := ApplyV10Config(&c, bad); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
+
+func TestFormatConfig(t *testing.T) {
+	out := FormatConfig(p2p.Configuration{NodeName: "n", PingInterval: time.Second})
+	if !strings.Contains(out, "NodeName=n\n") || !strings.Contains(out, "PingInterval=1s\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
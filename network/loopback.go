@@ -0,0 +1,101 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Loopback is a network without any peers. Every delivered message is read
+// back by this node, which makes it possible to measure the app's own
+// throughput without any networking involved.
+type Loopback struct {
+	queue chan []byte
+
+	sent     uint64
+	received uint64
+	dropped  uint64
+
+	metricsMtx sync.RWMutex
+	metrics    Metrics
+}
+
+var _ Network = (*Loopback)(nil)
+
+const loopbackPeer = "loopback"
+
+func NewLoopback(capacity int) *Loopback {
+	lb := new(Loopback)
+	lb.queue = make(chan []byte, capacity)
+	return lb
+}
+
+func (lb *Loopback) SetSpecialPeers(peers []string, exclusive string) error { return nil }
+func (lb *Loopback) Configure(overrides string) error                       { return nil }
+func (lb *Loopback) Config() string                                         { return "" }
+
+func (lb *Loopback) Init(name, port, seed string, bcast int) (func(), error) {
+	return func() {}, nil
+}
+
+func (lb *Loopback) SetFanout(fanout int) bool { return false }
+
+func (lb *Loopback) Name() string    { return loopbackPeer }
+func (lb *Loopback) Peers() []string { return nil }
+func (lb *Loopback) Metrics() Metrics {
+	lb.metricsMtx.RLock()
+	defer lb.metricsMtx.RUnlock()
+	return lb.metrics
+}
+
+func (lb *Loopback) processMetrics() {
+	ticker := time.NewTicker(time.Second)
+	var oldSent, oldReceived uint64
+	for range ticker.C {
+		sent := atomic.LoadUint64(&lb.sent)
+		received := atomic.LoadUint64(&lb.received)
+		lb.metricsMtx.Lock()
+		lb.metrics = Metrics{MessagesUp: sent - oldSent, MessagesDown: received - oldReceived}
+		lb.metricsMtx.Unlock()
+		oldSent, oldReceived = sent, received
+	}
+}
+
+func (lb *Loopback) Start() {
+	go lb.processMetrics()
+}
+
+// DeliverMessage queues the message to be read again. Messages are dropped if
+// the queue is full.
+func (lb *Loopback) DeliverMessage(target string, payload []byte) {
+	select {
+	case lb.queue <- payload:
+		atomic.AddUint64(&lb.sent, 1)
+	default:
+		atomic.AddUint64(&lb.dropped, 1)
+	}
+}
+
+func (lb *Loopback) ReadMessage() (string, []byte) {
+	msg := <-lb.queue
+	atomic.AddUint64(&lb.received, 1)
+	return loopbackPeer, msg
+}
+
+// Counts returns the total amount of messages sent, received, and dropped
+func (lb *Loopback) Counts() (uint64, uint64, uint64) {
+	return atomic.LoadUint64(&lb.sent), atomic.LoadUint64(&lb.received), atomic.LoadUint64(&lb.dropped)
+}
+
+// Pending returns the number of messages waiting to be read
+func (lb *Loopback) Pending() int {
+	return len(lb.queue)
+}
+
+func (lb *Loopback) Backlog() int {
+	return lb.Pending()
+}
+
+func (lb *Loopback) FullBroadcastFlag() string { return "<FULLBROADCAST>" }
+func (lb *Loopback) BroadcastFlag() string     { return "<BROADCAST>" }
+func (lb *Loopback) RandomFlag() string        { return "<RANDOM>" }
@@ -3,12 +3,25 @@ package network
 const NetworkID = 0xf00b47
 
 type Network interface {
+	// SetSpecialPeers sets the special peers and the exclusive mode, has to
+	// be called before Init
+	SetSpecialPeers(peers []string, exclusive string) error
+	// Configure overrides settings of the library with "Field=value" lines,
+	// has to be called before Init
+	Configure(overrides string) error
+	// Config lists the active settings of the library
+	Config() string
 	Init(name, port, seed string, bcast int) (func(), error)
+	// SetFanout changes how many peers a broadcast goes to while running,
+	// zero restores the fanout given to Init. Returns false if the library
+	// can't change it after Init.
+	SetFanout(fanout int) bool
 	Name() string
 	Peers() []string
 	Metrics() Metrics
 	DeliverMessage(string, []byte)
 	ReadMessage() (string, []byte)
+	Backlog() int
 	Start()
 	FullBroadcastFlag() string
 	BroadcastFlag() string
@@ -23,8 +36,8 @@ type Metrics struct {
 }
 
 func (m Metrics) BytesDownF() string {
-	return prettyBytes(m.BytesDown) + "/s"
+	return PrettyBytes(m.BytesDown) + "/s"
 }
 func (m Metrics) BytesUpF() string {
-	return prettyBytes(m.BytesUp) + "/s"
+	return PrettyBytes(m.BytesUp) + "/s"
 }
@@ -0,0 +1,49 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The exclusive modes restrict a node to its special peers, like the
+// authority nodes on mainnet that only talk to a fixed set of peers. Only
+// p2p1 supports them, p2p2 only has special peers.
+const (
+	// ExclusiveOff connects to special peers in addition to everyone else
+	ExclusiveOff = "off"
+	// Exclusive only dials the special peers but still accepts anyone
+	Exclusive = "exclusive"
+	// ExclusiveIn only dials and accepts the special peers
+	ExclusiveIn = "exclusive-in"
+)
+
+var validExclusive = []string{ExclusiveOff, Exclusive, ExclusiveIn}
+
+// ExclusiveModes returns the available exclusive modes
+func ExclusiveModes() []string {
+	return validExclusive
+}
+
+// ParseSpecialPeers reads "ip:port" addresses separated by commas, spaces,
+// or new lines
+func ParseSpecialPeers(peers string) ([]string, error) {
+	fields := strings.FieldsFunc(peers, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	for _, f := range fields {
+		if _, _, err := net.SplitHostPort(f); err != nil {
+			return nil, fmt.Errorf("invalid special peer \"%s\": %v", f, err)
+		}
+	}
+	return fields, nil
+}
+
+func validExclusiveMode(mode string) error {
+	for _, m := range validExclusive {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown exclusive mode \"%s\"", mode)
+}
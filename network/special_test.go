@@ -0,0 +1,23 @@
+package network
+
+import "testing"
+
+func TestV10_SetSpecialPeers(t *testing.T) {
+	v10 := NewV10(10)
+	if err := v10.SetSpecialPeers([]string{"10.0.0.1:8110"}, ExclusiveOff); err != nil {
+		t.Errorf("ExclusiveOff: %v", err)
+	}
+	for _, mode := range []string{Exclusive, ExclusiveIn, "bogus"} {
+		if err := v10.SetSpecialPeers([]string{"10.0.0.1:8110"}, mode); err == nil {
+			t.Errorf("%s: expected an error", mode)
+		}
+	}
+
+	if err := v10.Configure("MaxPeers=3\nTargetPeers=2\nDropTo=2"); err != nil {
+		t.Fatal(err)
+	}
+	c := v10.(*V10).config
+	if c.MaxPeers != 3 || c.TargetPeers != 2 || c.DropTo != 2 {
+		t.Errorf("overrides not kept: %+v", c)
+	}
+}
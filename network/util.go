@@ -2,7 +2,8 @@ package network
 
 import "fmt"
 
-func prettyBytes(bytes uint64) string {
+// PrettyBytes formats the amount of bytes with a binary unit
+func PrettyBytes(bytes uint64) string {
 	b := float64(bytes)
 	if b < 1024 {
 		return fmt.Sprintf("%.2f B", b)
@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	p2p "github.com/WhoSoup/factom-p2p"
@@ -10,8 +11,9 @@ import (
 )
 
 type V10 struct {
-	config p2p.Configuration
-	n      *p2p.Network
+	config  p2p.Configuration
+	n       *p2p.Network
+	special []string
 
 	metrics   Metrics
 	connected []string
@@ -65,12 +67,42 @@ func (v10 *V10) Start() {
 	go v10.processMetrics()
 	log.Fatal().Err(v10.n.Run())
 }
+
+// SetSpecialPeers sets the special peers. p2p2 has no exclusive flag and its
+// limits can't keep out everyone but the special peers without also refusing
+// them, so only ExclusiveOff is supported.
+func (v10 *V10) SetSpecialPeers(peers []string, exclusive string) error {
+	if err := validExclusiveMode(exclusive); err != nil {
+		return err
+	}
+	if exclusive != ExclusiveOff {
+		return fmt.Errorf("exclusive mode \"%s\" is only supported by p2p1", exclusive)
+	}
+	v10.special = peers
+	return nil
+}
+
+func (v10 *V10) Configure(overrides string) error {
+	config := v10.config
+	if err := ApplyV10Config(&config, overrides); err != nil {
+		return err
+	}
+	v10.config = config
+	return nil
+}
+
+func (v10 *V10) Config() string {
+	return FormatConfig(v10.config)
+}
+
 func (v10 *V10) Init(name, port, seed string, bcast int) (func(), error) {
 	v10.config.NodeName = name
 	v10.config.SeedURL = seed
 	v10.config.ListenPort = port
 	v10.config.Fanout = uint(bcast)
 	v10.config.NodeID = rand.Uint32()
+	v10.config.Special = strings.Join(v10.special, ",")
+
 	nn, err := p2p.NewNetwork(v10.config)
 	if err != nil {
 		return nil, err
@@ -78,6 +110,11 @@ func (v10 *V10) Init(name, port, seed string, bcast int) (func(), error) {
 	v10.n = nn
 	return func() {}, nil
 }
+
+// SetFanout can't change the fanout, p2p2 copies its configuration when the
+// network is created
+func (v10 *V10) SetFanout(fanout int) bool { return false }
+
 func (v10 *V10) Peers() []string {
 	return v10.connected
 }
@@ -95,6 +132,14 @@ func (v10 *V10) ReadMessage() (string, []byte) {
 	return p.Address, p.Payload
 }
 
+// Backlog is the number of messages waiting in the library's inbound channel
+func (v10 *V10) Backlog() int {
+	if v10.n == nil {
+		return 0
+	}
+	return len(v10.n.Reader())
+}
+
 func (v10 *V10) FullBroadcastFlag() string { return p2p.FullBroadcast }
 func (v10 *V10) BroadcastFlag() string     { return p2p.Broadcast }
 func (v10 *V10) RandomFlag() string        { return p2p.RandomPeer }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/FactomProject/factomd/common/messages"
@@ -16,8 +17,12 @@ import (
 
 type V9 struct {
 	name            string
+	special         []string
+	exclusive       string
 	metricsConsumer chan interface{}
 	controller      *p2p.Controller
+	init            p2p.ControllerInit
+	bcast           int
 
 	connected []string
 	metrics   Metrics
@@ -78,6 +83,28 @@ func (v9 *V9) Start() {
 func (v9 *V9) Name() string {
 	return fmt.Sprintf("%s-%d", v9.name, p2p.NodeID)
 }
+func (v9 *V9) SetSpecialPeers(peers []string, exclusive string) error {
+	if err := validExclusiveMode(exclusive); err != nil {
+		return err
+	}
+	v9.special = peers
+	v9.exclusive = exclusive
+	return nil
+}
+
+// Configure fails for any overrides, the old library is configured through
+// its package variables which are set by Init
+func (v9 *V9) Configure(overrides string) error {
+	if strings.TrimSpace(overrides) != "" {
+		return fmt.Errorf("the old library has no configurable settings")
+	}
+	return nil
+}
+
+func (v9 *V9) Config() string {
+	return FormatConfig(v9.init) + fmt.Sprintf("NumberPeersToBroadcast=%d\nNetworkDeadline=%s\nStandardChannelSize=%d\n", p2p.NumberPeersToBroadcast, p2p.NetworkDeadline, p2p.StandardChannelSize)
+}
+
 func (v9 *V9) Init(name, port, seed string, bcast int) (func(), error) {
 	file, err := ioutil.TempFile("", "peer.json")
 	if err != nil {
@@ -86,6 +113,7 @@ func (v9 *V9) Init(name, port, seed string, bcast int) (func(), error) {
 	v9.name = name
 	v9.metricsConsumer = make(chan interface{}, p2p.StandardChannelSize)
 	p2p.MinumumSharingQualityScore = 0
+	v9.bcast = bcast
 	p2p.NumberPeersToBroadcast = bcast
 	p2p.NetworkDeadline = time.Minute * 5
 	p2p.CurrentNetwork = NetworkID
@@ -97,17 +125,28 @@ func (v9 *V9) Init(name, port, seed string, bcast int) (func(), error) {
 		Port:                     port,
 		PeersFile:                file.Name(),
 		Network:                  NetworkID,
-		Exclusive:                false,
-		ExclusiveIn:              false,
+		Exclusive:                v9.exclusive == Exclusive || v9.exclusive == ExclusiveIn,
+		ExclusiveIn:              v9.exclusive == ExclusiveIn,
 		SeedURL:                  seed,
 		ConfigPeers:              "",
-		CmdLinePeers:             "",
+		CmdLinePeers:             strings.Join(v9.special, ","),
 		ConnectionMetricsChannel: v9.metricsConsumer,
 	}
+	v9.init = ci
 	v9.controller = new(p2p.Controller).Init(ci)
 	return func() { os.Remove(file.Name()) }, nil
 }
 
+// SetFanout changes p2p.NumberPeersToBroadcast, which the controller reads
+// for every broadcast
+func (v9 *V9) SetFanout(fanout int) bool {
+	if fanout <= 0 {
+		fanout = v9.bcast
+	}
+	p2p.NumberPeersToBroadcast = fanout
+	return true
+}
+
 func (v9 *V9) Peers() []string { return v9.connected }
 func (v9 *V9) DeliverMessage(target string, payload []byte) {
 	// we just need msg.GetMsgHash().Fixed(), nothing else
@@ -138,6 +177,14 @@ func (v9 *V9) ReadMessage() (string, []byte) {
 	return "", nil
 }
 
+// Backlog is the number of messages waiting in the controller's inbound channel
+func (v9 *V9) Backlog() int {
+	if v9.controller == nil {
+		return 0
+	}
+	return len(v9.controller.FromNetwork)
+}
+
 func (v9 *V9) FullBroadcastFlag() string { return p2p.FullBroadcastFlag }
 func (v9 *V9) BroadcastFlag() string     { return p2p.BroadcastFlag }
 func (v9 *V9) RandomFlag() string        { return p2p.RandomPeerFlag }
@@ -1,40 +1,308 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/WhoSoup/factom-p2p-tps/app"
 	"github.com/rs/zerolog/log"
 )
 
+// nodes renew their registration with the seed server every seedHeartbeat
+var seedHeartbeat = time.Second * 10
+
+// the seed server remembers the last seedFetchHistory requests for its list
+var seedFetchHistory = 100
+
+// SeedFetch is a request for the seed list
+type SeedFetch struct {
+	Time   time.Time `json:"time"`
+	Name   string    `json:"name"`
+	Port   string    `json:"port"`
+	Remote string    `json:"remote"`
+	Seeds  int       `json:"seeds"`
+	Fault  string    `json:"fault"`
+}
+
 type SeedServer struct {
-	port  string
-	seeds []string
+	port    string
+	list    *app.SeedList
+	started time.Time
+
+	mtx     sync.RWMutex
+	fault   *app.SeedFault
+	fetches []SeedFetch
 }
 
-func NewSeedServer(port, seeds string) *SeedServer {
+// NewSeedServer creates a seed server for the seed list that hands out the
+// addresses according to the topology. Nodes that register themselves are
+// added to the list.
+func NewSeedServer(port, seeds, topology, params string) (*SeedServer, error) {
 	srv := new(SeedServer)
 	srv.port = port
+	srv.started = time.Now()
 
-	split := strings.Split(seeds, "\n")
-	srv.seeds = make([]string, 0, len(split))
-	for _, s := range split {
-		s = strings.TrimSpace(s)
-		if s != "" {
-			srv.seeds = append(srv.seeds, s)
-		}
+	list, err := app.NewSeedList(app.ParseSeeds(seeds), topology, params)
+	if err != nil {
+		return nil, err
+	}
+	srv.list = list
+	srv.fault, _ = app.NewSeedFault("none", "")
+	return srv, nil
+}
+
+// SetFault switches the misbehaviour of the seed server
+func (s *SeedServer) SetFault(mode, params string) error {
+	fault, err := app.NewSeedFault(mode, params)
+	if err != nil {
+		return err
 	}
-	return srv
+	s.mtx.Lock()
+	s.fault = fault
+	s.mtx.Unlock()
+	log.Info().Str("mode", fault.Mode).Str("params", params).Msg("seed server fault")
+	return nil
 }
 
+// Fault returns the current misbehaviour
+func (s *SeedServer) Fault() *app.SeedFault {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.fault
+}
+
+// Run listens on the seed server's port and serves it
 func (s *SeedServer) Run() {
+	ln, err := s.Listen()
+	if err != nil {
+		log.Error().Err(err).Msg("unable to start seed server")
+		return
+	}
+	s.Serve(ln)
+}
+
+// Listen opens the seed server's port, so nodes can register before Serve runs
+func (s *SeedServer) Listen() (net.Listener, error) {
+	return net.Listen("tcp", fmt.Sprintf(":%s", s.port))
+}
+
+// Serve answers requests on the listener until it fails
+func (s *SeedServer) Serve(ln net.Listener) {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/seed.txt", func(rw http.ResponseWriter, req *http.Request) {
-		for _, s := range s.seeds {
-			fmt.Fprintln(rw, s)
+	mux.HandleFunc("/seed.txt", s.seeds)
+	mux.HandleFunc("/register", s.register)
+	mux.HandleFunc("/seeds", s.api)
+	mux.HandleFunc("/fault", s.faultf)
+	mux.HandleFunc("/fetches", s.fetchLog)
+	mux.HandleFunc("/health", s.health)
+	log.Info().Str("url", fmt.Sprintf("http://localhost:%s/seed.txt", s.port)).Msg("Starting seed server")
+	log.Error().Err(http.Serve(ln, mux))
+}
+
+func (s *SeedServer) seeds(rw http.ResponseWriter, req *http.Request) {
+	fault := s.Fault()
+	if !fault.Available(time.Now()) {
+		// drop the connection without an answer, like a server that is down
+		if hj, ok := rw.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	time.Sleep(fault.Delay())
+	if status := fault.Status(); status != 0 {
+		http.Error(rw, http.StatusText(status), status)
+		s.logFetch(req, 0, fault)
+		return
+	}
+	seeds := fault.Apply(s.list.Seeds(req.FormValue("name"), req.FormValue("port")))
+	for _, seed := range seeds {
+		fmt.Fprintln(rw, seed)
+	}
+	s.logFetch(req, len(seeds), fault)
+}
+
+func (s *SeedServer) logFetch(req *http.Request, seeds int, fault *app.SeedFault) {
+	f := SeedFetch{Time: time.Now(), Name: req.FormValue("name"), Port: req.FormValue("port"), Remote: req.RemoteAddr, Seeds: seeds, Fault: fault.Mode}
+	log.Info().Str("node", f.Name).Str("port", f.Port).Str("remote", f.Remote).Int("seeds", f.Seeds).Str("fault", f.Fault).Msg("seed list fetched")
+
+	s.mtx.Lock()
+	s.fetches = append(s.fetches, f)
+	if len(s.fetches) > seedFetchHistory {
+		s.fetches = s.fetches[len(s.fetches)-seedFetchHistory:]
+	}
+	s.mtx.Unlock()
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Error().Err(err).Msg("encoding json")
+	}
+}
+
+// api lists the seeds on GET, adds a static seed from the "name" and
+// "address" values on POST, replaces the static seeds with the seed list in
+// the body on PUT, and removes the seed with the "address" value on DELETE
+func (s *SeedServer) api(rw http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		addr := req.FormValue("address")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.list.AddStatic(app.SeedEntry{Name: req.FormValue("name"), Address: addr})
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
 		}
+		s.list.SetStatic(app.ParseSeeds(string(body)))
+	case http.MethodDelete:
+		if !s.list.Remove(req.FormValue("address")) {
+			http.Error(rw, "no such seed", http.StatusNotFound)
+			return
+		}
+	default:
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(rw, s.list.Status())
+}
+
+// faultf shows the current misbehaviour, or sets it from the "mode" and
+// "params" values on POST
+func (s *SeedServer) faultf(rw http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		if err := s.SetFault(req.FormValue("mode"), req.FormValue("params")); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	fault := s.Fault()
+	writeJSON(rw, map[string]string{"mode": fault.Mode, "params": fault.Params})
+}
+
+// fetchLog lists the last requests for the seed list, newest first
+func (s *SeedServer) fetchLog(rw http.ResponseWriter, req *http.Request) {
+	s.mtx.RLock()
+	fetches := make([]SeedFetch, len(s.fetches))
+	for i, f := range s.fetches {
+		fetches[len(fetches)-1-i] = f
+	}
+	s.mtx.RUnlock()
+	writeJSON(rw, fetches)
+}
+
+func (s *SeedServer) health(rw http.ResponseWriter, req *http.Request) {
+	writeJSON(rw, map[string]interface{}{
+		"status": "ok",
+		"uptime": time.Since(s.started).Round(time.Second).String(),
+		"seeds":  len(s.list.Live()),
+		"fault":  s.Fault().Mode,
 	})
-	log.Info().Str("url", fmt.Sprintf("http://localhost:%s/seed.txt", s.port)).Msg("Starting seed server")
-	log.Error().Err(http.ListenAndServe(fmt.Sprintf(":%s", s.port), mux))
+}
+
+// register adds the node to the seed list. The address is the ip the request
+// came from and the node's p2p port, unless the node sends a full address.
+// Loopback addresses are replaced by the advertised host, see SeedList.Rewrite.
+func (s *SeedServer) register(rw http.ResponseWriter, req *http.Request) {
+	addr := req.FormValue("address")
+	if addr == "" {
+		port := req.FormValue("port")
+		if _, err := strconv.Atoi(port); err != nil {
+			http.Error(rw, "invalid port", http.StatusBadRequest)
+			return
+		}
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	addr, err := s.list.Rewrite(addr)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.list.Register(req.FormValue("name"), addr)
+	fmt.Fprintln(rw, addr)
+}
+
+// seedURL adds the name and port of the node to the seed server's url, so a
+// seed server with a topology knows which node is asking
+func seedURL(seed, name, port string) (string, error) {
+	u, err := url.Parse(seed)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("name", name)
+	q.Set("port", port)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// registerURL is the registration endpoint of the seed server that serves
+// the given seed url. An advertised host is sent as the full address of the
+// node instead of the ip the request comes from.
+func registerURL(seed, name, port, advertise string) (string, error) {
+	u, err := url.Parse(seed)
+	if err != nil {
+		return "", err
+	}
+	u = u.ResolveReference(&url.URL{Path: "register"})
+	q := url.Values{}
+	q.Set("name", name)
+	q.Set("port", port)
+	if advertise != "" {
+		q.Set("address", net.JoinHostPort(advertise, port))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// registerSeed registers the node with the seed server once
+func registerSeed(client *http.Client, register string) error {
+	resp, err := client.Post(register, "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("seed server replied %s", resp.Status)
+	}
+	return nil
+}
+
+// heartbeatSeed keeps renewing the registration made by registerSeed. A
+// failing registration is reported once until it works again.
+func heartbeatSeed(register string) {
+	client := &http.Client{Timeout: seedHeartbeat}
+	warned := false
+	for {
+		time.Sleep(seedHeartbeat)
+		err := registerSeed(client, register)
+		if err != nil && !warned {
+			log.Warn().Err(err).Str("url", register).Msg("unable to register with seed server")
+			warned = true
+		} else if err == nil {
+			warned = false
+		}
+	}
 }
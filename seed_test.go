@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSeedServer_register(t *testing.T) {
+	srv, err := NewSeedServer("0", "", "static", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.list.Advertise = "192.168.1.5"
+
+	tests := []struct {
+		name, remote, host, form string
+		status                   int
+		want                     string
+	}{
+		{"remote node", "10.0.0.2:51234", "seed:8080", "name=a&port=8111", http.StatusOK, "10.0.0.2:8111"},
+		{"explicit address", "10.0.0.2:51234", "seed:8080", "name=b&address=10.0.0.3:8112", http.StatusOK, "10.0.0.3:8112"},
+		{"loopback uses advertised host", "127.0.0.1:51234", "localhost:8080", "name=c&port=8113", http.StatusOK, "192.168.1.5:8113"},
+		{"invalid port", "10.0.0.2:51234", "seed:8080", "name=d&port=abc", http.StatusBadRequest, ""},
+		{"invalid address", "10.0.0.2:51234", "seed:8080", "name=e&address=10.0.0.4", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tt.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.RemoteAddr = tt.remote
+			req.Host = tt.host
+			rw := httptest.NewRecorder()
+			srv.register(rw, req)
+			if rw.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rw.Code, tt.status)
+			}
+			if tt.want != "" && strings.TrimSpace(rw.Body.String()) != tt.want {
+				t.Errorf("registered %q, want %q", strings.TrimSpace(rw.Body.String()), tt.want)
+			}
+		})
+	}
+
+	var got []string
+	for _, e := range srv.list.Live() {
+		got = append(got, e.Address)
+	}
+	if want := "10.0.0.2:8111,10.0.0.3:8112,192.168.1.5:8113"; strings.Join(got, ",") != want {
+		t.Errorf("seed list = %v, want %s", got, want)
+	}
+}
+
+func TestRegisterSeed(t *testing.T) {
+	srv, err := NewSeedServer("0", "", "static", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.list.Advertise = "192.168.1.5"
+	ts := httptest.NewServer(http.HandlerFunc(srv.register))
+	defer ts.Close()
+
+	register, err := registerURL(ts.URL+"/seed.txt", "a", "8111", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	explicit, err := registerURL(ts.URL+"/seed.txt", "b", "8112", "10.0.0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{register, explicit} {
+		if err := registerSeed(ts.Client(), u); err != nil {
+			t.Fatalf("registerSeed(%s) = %v", u, err)
+		}
+	}
+
+	var got []string
+	for _, e := range srv.list.Live() {
+		got = append(got, e.Address)
+	}
+	if want := "192.168.1.5:8111,10.0.0.3:8112"; strings.Join(got, ",") != want {
+		t.Errorf("seed list = %v, want %s", got, want)
+	}
+
+	bad, _ := registerURL(ts.URL+"/seed.txt", "c", "abc", "")
+	if err := registerSeed(ts.Client(), bad); err == nil {
+		t.Error("registerSeed with an invalid port succeeded")
+	}
+}
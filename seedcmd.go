@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/WhoSoup/factom-p2p-tps/app"
+	"github.com/rs/zerolog/log"
+)
+
+// runSeedCommand starts a seed server on its own, without a node:
+//
+//	factom-p2p-tps seed -port 8112 -file seeds.txt -topology ring
+func runSeedCommand(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	port := fs.String("port", "8112", "the port of the seed server")
+	seeds := fs.String("seeds", "", "the seed addresses, comma separated, optionally as name=address")
+	file := fs.String("file", "", "a file with the seed list, one address per line, optionally after the node name")
+	topology := fs.String("topology", "static", "how to hand out the seeds: "+strings.Join(app.TopologyNames(), ", "))
+	params := fs.String("params", "", "the parameters of the topology")
+	shuffle := fs.Bool("shuffle", false, "shuffle the list on every request")
+	subset := fs.Int("subset", 0, "the number of seeds per request, 0 for all")
+	advertise := fs.String("advertise", "", "the host other machines reach this one at, replaces loopback addresses in registrations")
+	expiry := fs.Duration("expiry", time.Second*30, "how long a node's registration lasts without heartbeat, 0 to never expire")
+	fault := fs.String("fault", "none", "the misbehaviour of the seed server: "+strings.Join(app.SeedFaultNames(), ", "))
+	faultParams := fs.String("faultparams", "", "the parameters of the misbehaviour")
+	fs.Parse(args)
+
+	var content []string
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to read seed file")
+		}
+		content = append(content, string(data))
+	}
+	for _, s := range strings.Split(*seeds, ",") {
+		content = append(content, strings.Replace(s, "=", " ", 1))
+	}
+
+	srv, err := NewSeedServer(*port, strings.Join(content, "\n"), *topology, *params)
+	if err != nil {
+		log.Fatal().Err(err).Msg("unable to create seed server")
+	}
+	if *subset < 0 {
+		log.Fatal().Int("subset", *subset).Msg("the subset can't be negative")
+	}
+	srv.list.Shuffle = *shuffle
+	srv.list.Subset = *subset
+	srv.list.Expiry = *expiry
+	srv.list.Advertise = *advertise
+	if err := srv.SetFault(*fault, *faultParams); err != nil {
+		log.Fatal().Err(err).Msg("invalid fault")
+	}
+	srv.Run()
+}